@@ -25,8 +25,15 @@ func main() {
 
 	dataRoot := getenv("PVC_VIEWER_DATA_ROOT", "/data")
 	readOnly := getenv("PVC_VIEWER_READ_ONLY", "false") == "true"
+	// namespace agents mount one PVC per top-level directory
+	pvcDirs := getenv("PVC_VIEWER_PVC_DIRS", "false") == "true"
+	token := os.Getenv("PVC_VIEWER_AGENT_TOKEN")
+	insecure := getenv("PVC_VIEWER_AGENT_INSECURE", "false") == "true"
+	if token == "" && !insecure {
+		sugar.Fatalw("PVC_VIEWER_AGENT_TOKEN is required (set PVC_VIEWER_AGENT_INSECURE=true to accept unauthenticated requests)")
+	}
 
-	sugar.Infow("agent config", "dataRoot", dataRoot, "readOnly", readOnly)
+	sugar.Infow("agent config", "dataRoot", dataRoot, "readOnly", readOnly, "pvcDirs", pvcDirs, "auth", token != "")
 
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
@@ -40,6 +47,8 @@ func main() {
 	})
 
 	srvImpl := agent.NewHTTPServer(dataRoot, readOnly)
+	srvImpl.Token = token
+	srvImpl.PVCDirs = pvcDirs
 	r.Mount("/", srvImpl.Router)
 
 	srv := &http.Server{Addr: ":8090", Handler: r}
@@ -3,11 +3,15 @@ package main
 import (
 	"context"
 	"embed"
+	"encoding/json"
+	"errors"
+	"io"
 	iofs "io/fs"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
+	"path"
 	"strings"
 	"syscall"
 	"time"
@@ -16,11 +20,13 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"go.uber.org/zap"
 
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/audit"
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/auth"
 	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/backend"
 	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
 	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/kube"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/dynamic"
 )
 
 //go:embed static/*
@@ -38,27 +44,99 @@ func main() {
 
 	cfgState := config.NewState()
 	// Kube client
-	clientset, _, err := kube.NewClient()
+	clientset, restCfg, err := kube.NewClient()
 	if err != nil {
 		sugar.Fatalw("kube client", "error", err)
 	}
-	controller := &backend.Controller{Recon: &backend.Reconciler{Client: clientset, AgentImage: getenv("PVC_VIEWER_AGENT_IMAGE", "ghcr.io/example/pvc-viewer-agent:latest"), Defaults: cfgState.Current().Agents.SecurityDefaults, Overrides: cfgState.Current().Agents.SecurityOverrides, Logger: sugar}, Disc: &backend.Discovery{Client: clientset}, Logger: sugar}
+	podNamespace := getenv("POD_NAMESPACE", "default")
+	// Per-install token shared by all replicas; agents reject requests without it
+	agentToken, err := backend.EnsureAgentToken(ctx, clientset, podNamespace)
+	if err != nil {
+		sugar.Fatalw("agent token", "error", err)
+	}
+	// Cluster-scoped anchor owning all agent objects, so uninstall cleans them up via Kubernetes GC
+	var owner *metav1.OwnerReference
+	if anchor := getenv("PVC_VIEWER_OWNER_ANCHOR", ""); anchor != "" {
+		if owner, err = backend.ResolveOwnerAnchor(ctx, clientset, anchor); err != nil {
+			sugar.Warnw("owner anchor not found; agents will not be garbage-collected on uninstall", "anchor", anchor, "error", err)
+		}
+	}
+	// In mount-in-backend mode PVCs are mounted into this Pod, which always runs in POD_NAMESPACE
+	mounts := backend.NewMountDataPlane(podNamespace, sugar)
+	// Shared informers: discovery, list APIs and reconciliation read from this cache
+	kcache := backend.NewCache(clientset, 10*time.Minute)
+	disc := &backend.Discovery{Client: clientset, Mounts: mounts, Cache: kcache}
+	// PVCViewerPolicy objects (the chart installs the CRD when policies.enabled)
+	if getenv("PVC_VIEWER_POLICIES", "false") == "true" {
+		dyn, err := dynamic.NewForConfig(restCfg)
+		if err != nil {
+			sugar.Fatalw("dynamic client", "error", err)
+		}
+		disc.Policies = backend.NewPolicies(dyn, 10*time.Minute)
+	}
+	controller := &backend.Controller{Recon: &backend.Reconciler{Client: clientset, AgentImage: getenv("PVC_VIEWER_AGENT_IMAGE", "ghcr.io/example/pvc-viewer-agent:latest"), AgentToken: agentToken, Owner: owner, Logger: sugar, Cache: kcache}, Disc: disc, Mounts: mounts, Logger: sugar}
+	statusSvc := &backend.StatusService{Client: clientset, Disc: disc, Mounts: mounts}
+	stream := &backend.Stream{Disc: disc, Status: statusSvc, Logger: sugar}
+	auditLog := audit.NewLogger(sugar)
+	defer auditLog.Close()
 	if err := config.WatchFile(ctx, cfgPath, func(c *config.Config) {
 		cfgState.ApplyNewConfig(c)
-		controller.Recon.Defaults = c.Agents.SecurityDefaults
-		controller.Recon.Overrides = c.Agents.SecurityOverrides
+		auditLog.Apply(c.Audit.Sinks)
 		controller.OnConfigChange(ctx, c)
+		stream.ConfigReloaded()
+		backend.RecordConfigReload(nil)
+	}, func(err error) {
+		// keep serving the last good config
+		cfgState.RejectConfig(err)
+		backend.RecordConfigReload(err)
 	}); err != nil {
 		sugar.Fatalw("failed to start config watcher", "error", err)
 	}
-	// periodic reconcile to self-heal
-	controller.StartPeriodic(ctx, cfgState.Current, time.Minute)
+	// Authentication is set up once from the initial config; the chart rolls the Pod on ConfigMap changes
+	var authn *auth.Authenticator
+	if spec := cfgState.Current().Auth; spec.Enabled {
+		authn, err = auth.NewAuthenticator(ctx, spec, clientset, os.Getenv("PVC_VIEWER_OIDC_CLIENT_SECRET"), []byte(os.Getenv("PVC_VIEWER_SESSION_KEY")), sugar)
+		if err != nil {
+			sugar.Fatalw("auth setup failed", "error", err)
+		}
+	}
+	// Leader election: only the leader reconciles/GCs; the Lease outlives ctx so the shutdown hook
+	// can still tell whether this replica was leading
+	leCtx, leCancel := context.WithCancel(context.Background())
+	defer leCancel()
+	if getenv("PVC_VIEWER_LEADER_ELECT", "true") == "true" {
+		identity := getenv("POD_NAME", "")
+		if identity == "" {
+			identity, _ = os.Hostname()
+		}
+		if err := controller.RunLeaderElection(leCtx, clientset, podNamespace, identity, cfgState.Current); err != nil {
+			sugar.Fatalw("leader election", "error", err)
+		}
+	}
+	// Event-driven reconciliation; blocks until the informer caches are synced
+	if err := controller.Start(ctx, cfgState.Current, 2); err != nil {
+		sugar.Fatalw("controller start failed", "error", err)
+	}
+	// periodic full reconcile to self-heal
+	controller.StartPeriodic(ctx, time.Minute)
+	stream.Start(ctx, cfgState.Current)
 
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(60 * time.Second))
+	// the event stream is long-lived and must not be cut by the request timeout
+	timeout := middleware.Timeout(60 * time.Second)
+	r.Use(func(next http.Handler) http.Handler {
+		withTimeout := timeout(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/api/v1/events" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			withTimeout.ServeHTTP(w, r)
+		})
+	})
 
 	// Health endpoints
 	r.Get("/api/v1/healthz", func(w http.ResponseWriter, _ *http.Request) {
@@ -66,20 +144,57 @@ func main() {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok"))
 	})
+	// readyz: "degraded" while a rejected config is ignored, 503 until a valid config was loaded
 	r.Get("/api/v1/readyz", func(w http.ResponseWriter, _ *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte("ready"))
+		body := map[string]interface{}{"status": "ready", "leader": controller.IsLeader()}
+		code := http.StatusOK
+		if err := cfgState.LastError(); err != nil {
+			body["status"], body["configError"] = "degraded", err.Error()
+		}
+		if !cfgState.Loaded() {
+			body["status"], code = "unready", http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(body)
 	})
 
+	if authn != nil {
+		authn.RegisterRoutes(r)
+	}
+
 	// Agent proxy
-	proxy := backend.NewAgentProxy(clientset)
+	proxy := backend.NewAgentProxy(clientset, agentToken)
 	// Metrics endpoint
 	r.Handle("/metrics", backend.MetricsHandler())
 
+	// Authorizer follows the config: RBAC rules or SubjectAccessReview (decision cache lives across reloads)
+	reviewer := kube.NewAccessReviewer(clientset, cfgState.Current().RBAC.CacheTTL)
+	authz := func() auth.Authorizer {
+		cfg := cfgState.Current()
+		if cfg.RBAC.Mode == "kubernetes" {
+			return &auth.KubeAuthorizer{Reviewer: reviewer}
+		}
+		return auth.NewRBAC(cfg.RBAC.Rules)
+	}
+
+	forward := newForward(sugar, cfgState, mounts, disc, proxy)
+
 	// API
 	r.Route("/api/v1", func(api chi.Router) {
-		backend.RegisterReadAPIs(api, clientset, cfgState.Current)
-		api.Post("/gc", func(w http.ResponseWriter, r *http.Request) {
+		if authn != nil {
+			api.Use(authn.Middleware)
+		}
+		api.Get("/me", func(w http.ResponseWriter, r *http.Request) {
+			id, ok := auth.FromContext(r.Context())
+			if !ok {
+				id = &auth.Identity{Username: "anonymous", Groups: []string{}}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(id)
+		})
+		backend.RegisterReadAPIs(api, disc, cfgState.Current, authz)
+		api.Post("/gc", auth.Require(authz, auth.VerbAdmin, func(w http.ResponseWriter, r *http.Request) {
 			sugar.Infow("manual GC requested")
 			controller.Recon.Disabled.Store(true)
 			defer controller.Recon.Disabled.Store(false)
@@ -90,79 +205,74 @@ func main() {
 				sugar.Warnw("gc ns agents failed", "error", err)
 			}
 			w.WriteHeader(http.StatusNoContent)
-		})
-		api.Get("/tree", func(w http.ResponseWriter, r *http.Request) {
-			ns := r.URL.Query().Get("ns")
-			pvc := r.URL.Query().Get("pvc")
-			sugar.Infow("/tree", "ns", ns, "pvc", pvc, "rawPath", r.URL.Query().Get("path"))
-			svc, newRaw := computeRouting(clientset, cfgState.Current(), ns, pvc, r.URL.Query().Get("path"), r.URL.RawQuery)
-			rc := r.Clone(r.Context())
-			rc.URL.RawQuery = newRaw
-			if err := proxy.Proxy(r.Context(), ns, svc, "/v1/tree", w, rc); err != nil {
-				sugar.Warnw("proxy tree failed", "ns", ns, "pvc", pvc, "svc", svc, "error", err)
-				http.Error(w, "agent unavailable", http.StatusBadGateway)
+		}))
+		api.Get("/tree", auditLog.Middleware("tree", auth.Require(authz, auth.VerbList, forward("tree", "/v1/tree"))))
+		api.Get("/stat", auditLog.Middleware("stat", auth.Require(authz, auth.VerbList, forward("stat", "/v1/stat"))))
+		api.Get("/download", auditLog.Middleware("download", auth.Require(authz, auth.VerbDownload, forward("download", "/v1/file"))))
+		api.Delete("/file", auditLog.Middleware("delete", auth.Require(authz, auth.VerbDelete, forward("delete", "/v1/file"))))
+		api.Post("/upload", auditLog.Middleware("upload", auth.Require(authz, auth.VerbUpload, forward("upload", "/v1/upload"))))
+		api.Post("/empty-dir", auditLog.Middleware("empty-dir", auth.Require(authz, auth.VerbEmpty, forward("empty-dir", "/v1/empty"))))
+		// a move deletes the source and writes the destination
+		api.Post("/move", auditLog.Middleware("move", auth.Require(authz, auth.VerbDelete, auth.Require(authz, auth.VerbUpload, forward("move", "/v1/move")))))
+		// a copy reads the source and writes the destination; progress is polled with GET
+		api.Post("/copy", auditLog.Middleware("copy", auth.Require(authz, auth.VerbDownload, auth.Require(authz, auth.VerbUpload, forward("copy", "/v1/copy")))))
+		api.Get("/copy", auth.Require(authz, auth.VerbList, forward("copy-status", "/v1/copy")))
+		api.Post("/mkdir", auditLog.Middleware("mkdir", auth.Require(authz, auth.VerbUpload, forward("mkdir", "/v1/mkdir"))))
+		api.Post("/touch", auditLog.Middleware("touch", auth.Require(authz, auth.VerbUpload, forward("touch", "/v1/touch"))))
+		api.Post("/chmod", auditLog.Middleware("chmod", auth.Require(authz, auth.VerbUpload, forward("chmod", "/v1/chmod"))))
+		api.Get("/events", stream.Handler(authz))
+		api.Get("/config", auth.Require(authz, auth.VerbAdmin, func(w http.ResponseWriter, r *http.Request) {
+			doc, err := cfgState.Current().Document()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
-		})
-		api.Get("/download", func(w http.ResponseWriter, r *http.Request) {
-			ns := r.URL.Query().Get("ns")
-			pvc := r.URL.Query().Get("pvc")
-			sugar.Infow("/download", "ns", ns, "pvc", pvc, "path", r.URL.Query().Get("path"))
-			svc, newRaw := computeRouting(clientset, cfgState.Current(), ns, pvc, r.URL.Query().Get("path"), r.URL.RawQuery)
-			rc := r.Clone(r.Context())
-			rc.URL.RawQuery = newRaw
-			if err := proxy.Proxy(r.Context(), ns, svc, "/v1/file", w, rc); err != nil {
-				sugar.Warnw("proxy download failed", "ns", ns, "pvc", pvc, "svc", svc, "error", err)
-				http.Error(w, "agent unavailable", http.StatusBadGateway)
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(struct {
+				Config map[string]interface{} `json:"config"`
+				config.StateStatus
+			}{doc, cfgState.Status()})
+		}))
+		// dry-run: what the submitted config (YAML or JSON) would produce, without applying it
+		api.Post("/config/validate", auth.Require(authz, auth.VerbAdmin, func(w http.ResponseWriter, r *http.Request) {
+			b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
 				return
 			}
-		})
-		api.Delete("/file", func(w http.ResponseWriter, r *http.Request) {
-			ns := r.URL.Query().Get("ns")
-			pvc := r.URL.Query().Get("pvc")
-			sugar.Infow("/file DELETE", "ns", ns, "pvc", pvc, "path", r.URL.Query().Get("path"))
-			svc, newRaw := computeRouting(clientset, cfgState.Current(), ns, pvc, r.URL.Query().Get("path"), r.URL.RawQuery)
-			rc := r.Clone(r.Context())
-			rc.URL.RawQuery = newRaw
-			if err := proxy.Proxy(r.Context(), ns, svc, "/v1/file", w, rc); err != nil {
-				sugar.Warnw("proxy delete failed", "ns", ns, "pvc", pvc, "svc", svc, "error", err)
-				http.Error(w, "agent unavailable", http.StatusBadGateway)
+			w.Header().Set("Content-Type", "application/json")
+			c, err := config.Parse(b)
+			if err != nil {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"valid": false, "errors": strings.Split(err.Error(), "\n")})
 				return
 			}
-		})
-		api.Post("/upload", func(w http.ResponseWriter, r *http.Request) {
-			ns := r.URL.Query().Get("ns")
-			pvc := r.URL.Query().Get("pvc")
-			sugar.Infow("/upload", "ns", ns, "pvc", pvc, "path", r.URL.Query().Get("path"))
-			svc, newRaw := computeRouting(clientset, cfgState.Current(), ns, pvc, r.URL.Query().Get("path"), r.URL.RawQuery)
-			rc := r.Clone(r.Context())
-			rc.URL.RawQuery = newRaw
-			if err := proxy.Proxy(r.Context(), ns, svc, "/v1/upload", w, rc); err != nil {
-				sugar.Warnw("proxy upload failed", "ns", ns, "pvc", pvc, "svc", svc, "error", err)
-				http.Error(w, "agent unavailable", http.StatusBadGateway)
+			plan, err := disc.Plan(r.Context(), c)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
-		})
-		api.Post("/empty-dir", func(w http.ResponseWriter, r *http.Request) {
-			ns := r.URL.Query().Get("ns")
-			pvc := r.URL.Query().Get("pvc")
-			sugar.Infow("/empty-dir", "ns", ns, "pvc", pvc, "path", r.URL.Query().Get("path"))
-			svc, newRaw := computeRouting(clientset, cfgState.Current(), ns, pvc, r.URL.Query().Get("path"), r.URL.RawQuery)
-			rc := r.Clone(r.Context())
-			rc.URL.RawQuery = newRaw
-			if err := proxy.Proxy(r.Context(), ns, svc, "/v1/empty", w, rc); err != nil {
-				sugar.Warnw("proxy empty-dir failed", "ns", ns, "pvc", pvc, "svc", svc, "error", err)
-				http.Error(w, "agent unavailable", http.StatusBadGateway)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"valid": true, "plan": plan})
+		}))
+		api.Get("/explain", auth.Require(authz, auth.VerbList, func(w http.ResponseWriter, r *http.Request) {
+			ex, err := disc.Explain(r.Context(), cfgState.Current(), r.URL.Query().Get("ns"), r.URL.Query().Get("pvc"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
-		})
-		api.Get("/pvc-status", func(w http.ResponseWriter, r *http.Request) {
-			ns := r.URL.Query().Get("ns")
-			pvc := r.URL.Query().Get("pvc")
-			st, _ := (&backend.StatusService{Client: clientset}).GetStatus(r.Context(), ns, pvc)
 			w.Header().Set("Content-Type", "application/json")
-			_, _ = w.Write([]byte("\"" + string(st) + "\""))
-		})
+			_ = json.NewEncoder(w).Encode(ex)
+		}))
+		api.Get("/pvc-status", auth.Require(authz, auth.VerbList, func(w http.ResponseWriter, r *http.Request) {
+			st, err := statusSvc.GetStatus(r.Context(), cfgState.Current(), r.URL.Query().Get("ns"), r.URL.Query().Get("pvc"))
+			if err != nil {
+				sugar.Warnw("pvc status failed", "error", err)
+				http.Error(w, "status unavailable", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(st)
+		}))
 	})
 
 	// Static UI (embedded). Serve contents of subdir "static" as root.
@@ -170,7 +280,11 @@ func main() {
 	if err != nil {
 		sugar.Fatalw("embed FS error", "error", err)
 	}
-	r.Handle("/*", http.FileServer(http.FS(staticFS)))
+	var static http.Handler = http.FileServer(http.FS(staticFS))
+	if authn != nil {
+		static = authn.RequireLogin(static)
+	}
+	r.Handle("/*", static)
 
 	srv := &http.Server{Addr: ":8080", Handler: r}
 
@@ -182,20 +296,25 @@ func main() {
 	}()
 
 	<-ctx.Done()
-	sugar.Infow("shutting down")
-	// Best-effort cleanup of all agents on shutdown (e.g., Helm uninstall)
-	go func() {
-		// Disable reconciler during GC to avoid races
-		controller.Recon.Disabled.Store(true)
-		defer controller.Recon.Disabled.Store(false)
-		bg := context.Background()
-		if err := controller.Recon.GCPerPVCAll(bg); err != nil {
-			sugar.Warnw("gc per-pvc agents on shutdown failed", "error", err)
-		}
-		if err := controller.Recon.GCNamespaceAgents(bg, map[string]struct{}{}); err != nil {
-			sugar.Warnw("gc ns agents on shutdown failed", "error", err)
+	sugar.Infow("shutting down", "leader", controller.IsLeader())
+	// Agents normally survive backend restarts (rolling updates) and are removed on uninstall by
+	// Kubernetes GC through their owner anchor; agents.cleanupOnShutdown covers other setups
+	if controller.IsLeader() {
+		gcCtx, gcCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		policy := cfgState.Current().Agents.CleanupOnShutdown
+		if policy == backend.CleanupAlways || (policy == backend.CleanupUninstall && controller.Recon.AnchorGone(gcCtx)) {
+			sugar.Infow("removing all agents on shutdown", "policy", policy)
+			// Disable reconciler during GC to avoid races
+			controller.Recon.Disabled.Store(true)
+			if err := controller.Recon.GCPerPVCAll(gcCtx); err != nil {
+				sugar.Warnw("gc per-pvc agents on shutdown failed", "error", err)
+			}
+			if err := controller.Recon.GCNamespaceAgents(gcCtx, map[string]struct{}{}); err != nil {
+				sugar.Warnw("gc ns agents on shutdown failed", "error", err)
+			}
 		}
-	}()
+		gcCancel()
+	}
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	_ = srv.Shutdown(shutdownCtx)
@@ -214,41 +333,95 @@ func backendServiceName(ns, pvc string) string {
 
 // agent name generation is delegated to internal/backend.AgentName
 
-// computeRouting picks target Service and rewrites path for per-namespace agents
-func computeRouting(clientset kubernetes.Interface, cfg *config.Config, ns, pvc, path, rawQuery string) (svcName string, newRaw string) {
-	if cfg != nil && cfg.Mode.DataPlane == "agent-per-namespace" {
-		// namespace agent service; ensure path is under /data/<pvc>
-		q, _ := url.ParseQuery(rawQuery)
-		// path from r.URL.Query().Get("path") is already decoded, avoid double-decoding
-		decoded := path
-		if !strings.HasPrefix(decoded, "/") {
-			decoded = "/" + decoded
-		}
-		// Avoid double-prefixing if already under /<pvc>
-		prefix := "/" + pvc
-		if decoded == "/" {
-			q.Set("path", prefix+"/")
-		} else if decoded == prefix || strings.HasPrefix(decoded, prefix+"/") {
-			q.Set("path", decoded)
-		} else {
-			q.Set("path", prefix+decoded)
-		}
-		// choose service per security profile group
-		// resolve PVC storage class
-		sc := ""
-		if p, err := clientset.CoreV1().PersistentVolumeClaims(ns).Get(context.Background(), pvc, metav1.GetOptions{}); err == nil {
-			if p.Spec.StorageClassName != nil {
-				sc = *p.Spec.StorageClassName
-			} else if p.Spec.VolumeName != "" {
-				if pv, err2 := clientset.CoreV1().PersistentVolumes().Get(context.Background(), p.Spec.VolumeName, metav1.GetOptions{}); err2 == nil {
-					sc = pv.Spec.StorageClassName
+// newForward returns forward, which serves a data API in-process for mounted PVCs and otherwise
+// proxies to the agent Service
+func newForward(sugar *zap.SugaredLogger, cfgState *config.State, mounts *backend.MountDataPlane, disc *backend.Discovery, proxy *backend.AgentProxy) func(op, agentPath string) http.HandlerFunc {
+	return func(op, agentPath string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			ns := r.URL.Query().Get("ns")
+			pvc := r.URL.Query().Get("pvc")
+			sugar.Infow("/"+op, "ns", ns, "pvc", pvc, "path", r.URL.Query().Get("path"))
+			cfg := cfgState.Current()
+			if cfg.Mode.DataPlane == backend.ModeMountInBackend {
+				if !mounts.Serve(ns, pvc, agentPath, w, r) {
+					sugar.Warnw("pvc not mounted in backend", "op", op, "ns", ns, "pvc", pvc)
+					http.Error(w, "pvc not mounted", http.StatusNotFound)
 				}
+				return
+			}
+			svc, newRaw, err := computeRouting(r.Context(), disc, cfg, ns, pvc, r.URL.RawQuery)
+			if err != nil {
+				sugar.Warnw("rejected path", "op", op, "ns", ns, "pvc", pvc, "error", err)
+				http.Error(w, "bad path", http.StatusBadRequest)
+				return
 			}
+			rc := r.Clone(r.Context())
+			rc.URL.RawQuery = newRaw
+			if err := proxy.Proxy(r.Context(), ns, svc, agentPath, w, rc); err != nil {
+				sugar.Warnw("proxy "+op+" failed", "ns", ns, "pvc", pvc, "svc", svc, "error", err)
+				http.Error(w, "agent unavailable", http.StatusBadGateway)
+				return
+			}
+		}
+	}
+}
+
+// computeRouting picks target Service and rewrites path for per-namespace agents
+func computeRouting(ctx context.Context, disc *backend.Discovery, cfg *config.Config, ns, pvc, rawQuery string) (svcName string, newRaw string, err error) {
+	if cfg != nil && cfg.Mode.DataPlane == "agent-per-namespace" {
+		newRaw, err := pvcQuery(pvc, rawQuery)
+		if err != nil {
+			return "", "", err
 		}
-		// derive effective security and group key (PVC-specific override has precedence)
-		eff := backend.BuildSecuritySpec(cfg, pvc, sc)
-		key := backend.ProfileKey(eff)
-		return backend.NamespaceAgentGroupName(ns, key), q.Encode()
+		// choose service per security profile group (PVC-specific override has precedence)
+		return backend.AgentServiceName(cfg, disc.Target(ctx, cfg, ns, pvc)), newRaw, nil
 	}
-	return backend.AgentName(ns, pvc), rawQuery
+	return backend.AgentName(ns, pvc), rawQuery, nil
 }
+
+// pvcQuery confines path (and the move/copy destination) to /data/<pvc> of a namespace agent.
+func pvcQuery(pvc, rawQuery string) (string, error) {
+	q, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", err
+	}
+	for _, k := range []string{"path", "to"} {
+		if k == "to" && !q.Has(k) {
+			continue
+		}
+		p, err := pvcPath(pvc, q.Get(k))
+		if err != nil {
+			return "", err
+		}
+		q.Set(k, p)
+	}
+	return q.Encode(), nil
+}
+
+// pvcPath places p under /<pvc>, the PVC's directory in a namespace agent. ".." segments are
+// rejected and the cleaned result must stay /<pvc>/ or below, so a sibling PVC is never reached.
+func pvcPath(pvc, p string) (string, error) {
+	if pvc == "" || pvc == "." || pvc == ".." || strings.ContainsAny(pvc, `/\`) {
+		return "", errBadPath
+	}
+	for _, seg := range strings.Split(p, "/") {
+		if seg == ".." {
+			return "", errBadPath
+		}
+	}
+	prefix := "/" + pvc
+	clean := path.Clean("/" + p)
+	// Avoid double-prefixing if already under /<pvc> (tree entries carry the prefix)
+	if clean != prefix && !strings.HasPrefix(clean, prefix+"/") {
+		clean = path.Clean(prefix + clean)
+	}
+	if clean != prefix && !strings.HasPrefix(clean, prefix+"/") {
+		return "", errBadPath
+	}
+	if clean == prefix {
+		return prefix + "/", nil
+	}
+	return clean, nil
+}
+
+var errBadPath = errors.New("path escapes the PVC")
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/backend"
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
+)
+
+func TestPvcPath(t *testing.T) {
+	tests := []struct {
+		name, pvc, path, want string
+		wantErr               bool
+	}{
+		{name: "root", pvc: "data", path: "", want: "/data/"},
+		{name: "slash", pvc: "data", path: "/", want: "/data/"},
+		{name: "relative", pvc: "data", path: "logs/a.txt", want: "/data/logs/a.txt"},
+		{name: "absolute", pvc: "data", path: "/logs/a.txt", want: "/data/logs/a.txt"},
+		{name: "already prefixed", pvc: "data", path: "/data/logs", want: "/data/logs"},
+		{name: "prefix itself", pvc: "data", path: "/data", want: "/data/"},
+		{name: "similar prefix", pvc: "data", path: "/data2/x", want: "/data/data2/x"},
+		{name: "redundant slashes", pvc: "data", path: "//logs//./a", want: "/data/logs/a"},
+		{name: "dotdot to sibling", pvc: "data", path: "/../other/secret", wantErr: true},
+		{name: "dotdot inside prefix", pvc: "data", path: "/data/../other/secret", wantErr: true},
+		{name: "dotdot relative", pvc: "data", path: "../other", wantErr: true},
+		{name: "dotdot trailing", pvc: "data", path: "/logs/..", wantErr: true},
+		{name: "dotdot name is fine", pvc: "data", path: "/..hidden", want: "/data/..hidden"},
+		{name: "pvc dotdot", pvc: "..", path: "/x", wantErr: true},
+		{name: "pvc with slash", pvc: "a/b", path: "/x", wantErr: true},
+		{name: "empty pvc", pvc: "", path: "/x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pvcPath(tt.pvc, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("pvcPath(%q, %q) error = %v, wantErr %v", tt.pvc, tt.path, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("pvcPath(%q, %q) = %q, want %q", tt.pvc, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPvcQuery(t *testing.T) {
+	tests := []struct {
+		name, raw string
+		wantPath  string
+		wantErr   bool
+	}{
+		{name: "plain", raw: "ns=a&pvc=data&path=%2Flogs", wantPath: "/data/logs"},
+		{name: "encoded dotdot", raw: "ns=a&pvc=data&path=%2F%2E%2E%2Fother%2Fsecret", wantErr: true},
+		{name: "encoded dotdot lowercase", raw: "ns=a&pvc=data&path=%2e%2e/other", wantErr: true},
+		{name: "double encoded stays a name", raw: "ns=a&pvc=data&path=%252e%252e%2Fx", wantPath: "/data/%2e%2e/x"},
+		{name: "bad escape", raw: "path=%zz", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pvcQuery("data", tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("pvcQuery(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			q, _ := url.ParseQuery(got)
+			if q.Get("path") != tt.wantPath {
+				t.Fatalf("path = %q, want %q", q.Get("path"), tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestPvcQueryDestination(t *testing.T) {
+	tests := []struct {
+		name, raw, wantTo string
+		wantErr           bool
+	}{
+		{name: "no destination", raw: "path=%2Fa"},
+		{name: "destination prefixed", raw: "path=%2Fa&to=%2Fb", wantTo: "/data/b"},
+		{name: "move to sibling PVC", raw: "path=%2Fa&to=%2F..%2Fother%2Fx", wantErr: true},
+		{name: "move to encoded sibling PVC", raw: "path=%2Fa&to=%2F%2E%2E%2Fother%2Fx", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pvcQuery("data", tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("pvcQuery(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			q, _ := url.ParseQuery(got)
+			if q.Get("to") != tt.wantTo || q.Has("to") != (tt.wantTo != "") {
+				t.Fatalf("to = %q, want %q", q.Get("to"), tt.wantTo)
+			}
+		})
+	}
+}
+
+func TestForwardRejectsPathsOutsidePVC(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Mode.DataPlane = "agent-per-namespace"
+	state := config.NewState()
+	state.ApplyNewConfig(cfg)
+	log := zap.NewNop().Sugar()
+	forward := newForward(log, state, backend.NewMountDataPlane("default", log), &backend.Discovery{}, backend.NewAgentProxy(nil, ""))
+
+	tests := []struct {
+		name, op, agentPath, target string
+	}{
+		{name: "copy to sibling PVC", op: "copy", agentPath: "/v1/copy", target: "/api/v1/copy?ns=a&pvc=data&path=/src&to=/../x"},
+		{name: "copy from sibling PVC", op: "copy", agentPath: "/v1/copy", target: "/api/v1/copy?ns=a&pvc=data&path=/../other&to=/x"},
+		{name: "move to sibling PVC", op: "move", agentPath: "/v1/move", target: "/api/v1/move?ns=a&pvc=data&path=/a&to=%2F%2E%2E%2Fother%2Fa"},
+		{name: "download from sibling PVC", op: "download", agentPath: "/v1/file", target: "/api/v1/download?ns=a&pvc=data&path=/../other/secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			forward(tt.op, tt.agentPath)(rr, httptest.NewRequest(http.MethodPost, tt.target, nil))
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("got %d, want 400", rr.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/bmatcuk/doublestar/v4"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/fsutil"
+)
+
+const maxChmodResults = 5000
+
+// ChmodResult is the outcome for one entry. Owned tells whether the agent's effective UID owns
+// the entry: only the owner (or root) may change its mode, other entries fail with EPERM.
+type ChmodResult struct {
+	Path    string `json:"path"`
+	Mode    uint32 `json:"mode"`
+	NewMode uint32 `json:"newMode,omitempty"`
+	UID     uint32 `json:"uid"`
+	Owned   bool   `json:"owned"`
+	Changed bool   `json:"changed"`
+	Skipped string `json:"skipped,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ChmodResponse lists the results; Truncated when more than maxChmodResults entries were visited.
+type ChmodResponse struct {
+	AgentUID  int           `json:"agentUID"`
+	Changed   int           `json:"changed"`
+	Failed    int           `json:"failed"`
+	Results   []ChmodResult `json:"results"`
+	Truncated bool          `json:"truncated,omitempty"`
+}
+
+// handleChmod sets the permission bits of path to mode (octal). With recursive=true the entries
+// below path are changed too, filtered by include/exclude globs on their path relative to path
+// (an excluded directory is not descended); path itself is changed unless include is given.
+// Symlinks below path are skipped, never followed.
+func (s *HTTPServer) handleChmod(w http.ResponseWriter, r *http.Request) {
+	if s.ReadOnly {
+		s.Logger.Warnw("chmod in read-only mode")
+		http.Error(w, "read-only", http.StatusForbidden)
+		return
+	}
+	q := r.URL.Query()
+	p := q.Get("path")
+	if q.Get("mode") == "" {
+		http.Error(w, "mode is required", http.StatusBadRequest)
+		return
+	}
+	mode, _, ok := modeFromQuery(q.Get("mode"), 0)
+	if !ok {
+		http.Error(w, "mode must be octal permission bits (e.g. 0640)", http.StatusBadRequest)
+		return
+	}
+	recursive := q.Get("recursive") == "true"
+	include, exclude := q["include"], q["exclude"]
+	for _, g := range append(append([]string{}, include...), exclude...) {
+		if !doublestar.ValidatePattern(g) {
+			http.Error(w, "invalid glob "+g, http.StatusBadRequest)
+			return
+		}
+	}
+	full, err := fsutil.JoinSecure(s.DataRoot, p)
+	if err != nil {
+		s.Logger.Warnw("join secure failed", "path", p, "error", err)
+		http.Error(w, "bad path", http.StatusBadRequest)
+		return
+	}
+	fi, err := os.Stat(full)
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	root, _ := fsutil.JoinSecure(s.DataRoot, "/")
+	if s.PVCDirs && full == root {
+		http.Error(w, "the root holds the PVC directories", http.StatusBadRequest)
+		return
+	}
+	s.Logger.Infow("chmod", "path", p, "mode", q.Get("mode"), "recursive", recursive, "include", include, "exclude", exclude)
+
+	resp := ChmodResponse{AgentUID: os.Geteuid(), Results: []ChmodResult{}}
+	add := func(res ChmodResult) {
+		switch {
+		case res.Error != "":
+			resp.Failed++
+		case res.Changed:
+			resp.Changed++
+		}
+		if len(resp.Results) < maxChmodResults {
+			resp.Results = append(resp.Results, res)
+		} else {
+			resp.Truncated = true
+		}
+	}
+	if len(include) == 0 {
+		add(chmodEntry(root, p, full, fi, mode))
+	}
+	if recursive && fi.IsDir() {
+		_ = filepath.WalkDir(full, func(cur string, d fs.DirEntry, err error) error {
+			if cur == full {
+				return nil
+			}
+			rel, _ := filepath.Rel(full, cur)
+			rel = filepath.ToSlash(rel)
+			if matchAny(exclude, rel) {
+				if d != nil && d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if len(include) > 0 && !matchAny(include, rel) {
+				return nil
+			}
+			reqPath := filepath.Join(p, rel)
+			if err != nil {
+				add(ChmodResult{Path: reqPath, Error: errText(err)})
+				return nil
+			}
+			efi, err := d.Info()
+			if err != nil {
+				add(ChmodResult{Path: reqPath, Error: errText(err)})
+				return nil
+			}
+			if d.Type()&fs.ModeSymlink != 0 {
+				add(ChmodResult{Path: reqPath, Mode: uint32(efi.Mode().Perm()), UID: ownerUID(efi), Owned: ownerUID(efi) == uint32(os.Geteuid()), Skipped: "symlink"})
+				return nil
+			}
+			add(chmodEntry(root, reqPath, cur, efi, mode))
+			return nil
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// chmodEntry sets the mode of full as described by its walked fi, never through a symlink.
+func chmodEntry(root, reqPath, full string, fi os.FileInfo, mode os.FileMode) ChmodResult {
+	uid := ownerUID(fi)
+	res := ChmodResult{Path: reqPath, Mode: uint32(fi.Mode().Perm()), UID: uid, Owned: uid == uint32(os.Geteuid())}
+	if fi.Mode().Perm() == mode {
+		return res
+	}
+	if err := chmodNoFollow(root, full, mode); err != nil {
+		res.Error = errText(err)
+		return res
+	}
+	res.NewMode, res.Changed = uint32(mode), true
+	return res
+}
+
+func ownerUID(fi os.FileInfo) uint32 {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return st.Uid
+	}
+	return 0
+}
+
+func matchAny(patterns []string, p string) bool {
+	for _, g := range patterns {
+		if ok, _ := doublestar.Match(g, p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// errText is the error without the path, which the result already carries.
+func errText(err error) string {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		return pe.Err.Error()
+	}
+	return err.Error()
+}
@@ -0,0 +1,40 @@
+package agent
+
+import (
+	"errors"
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/fsutil"
+)
+
+var (
+	errChmodSymlink = errors.New("is a symlink, not followed")
+	errChmodOutside = errors.New("resolves outside the data root")
+)
+
+// chmodNoFollow sets the mode of full, which may have been swapped since it was walked: it is
+// opened without following a final symlink, the opened file must still lie within root, and the
+// mode is set through the descriptor.
+func chmodNoFollow(root, full string, mode os.FileMode) error {
+	fd, err := unix.Open(full, unix.O_PATH|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return &os.PathError{Op: "open", Path: full, Err: err}
+	}
+	defer unix.Close(fd)
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return &os.PathError{Op: "fstat", Path: full, Err: err}
+	}
+	if st.Mode&unix.S_IFMT == unix.S_IFLNK {
+		return errChmodSymlink
+	}
+	// an O_PATH descriptor cannot be fchmod'ed; its /proc link refers to the opened inode
+	proc := "/proc/self/fd/" + strconv.Itoa(fd)
+	if real, err := os.Readlink(proc); err != nil || !fsutil.Within(root, real) {
+		return errChmodOutside
+	}
+	return os.Chmod(proc, mode)
+}
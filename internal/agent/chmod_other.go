@@ -0,0 +1,23 @@
+//go:build !linux
+
+package agent
+
+import (
+	"errors"
+	"os"
+)
+
+var errChmodSymlink = errors.New("is a symlink, not followed")
+
+// chmodNoFollow sets the mode of full unless it is a symlink; only the Linux version is free of
+// races with concurrent renames. Agents run on Linux.
+func chmodNoFollow(root, full string, mode os.FileMode) error {
+	fi, err := os.Lstat(full)
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		return errChmodSymlink
+	}
+	return os.Chmod(full, mode)
+}
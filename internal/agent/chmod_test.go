@@ -0,0 +1,171 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// runChmod serves a chmod and decodes its response.
+func runChmod(t *testing.T, s *HTTPServer, query string) ChmodResponse {
+	t.Helper()
+	rr := do(s, http.MethodPost, "/v1/chmod?"+query)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("chmod %s: got %d %q", query, rr.Code, rr.Body.String())
+	}
+	var resp ChmodResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// chmodTree creates d with files and subdirectories, all 0644/0755.
+func chmodTree(t *testing.T, root string) {
+	writeFile(t, root, "d/a.sh", "a", 0o644)
+	writeFile(t, root, "d/b.txt", "b", 0o644)
+	writeFile(t, root, "d/sub/c.sh", "c", 0o644)
+	writeFile(t, root, "d/skip/e.sh", "e", 0o644)
+}
+
+// wantModes checks the permission bits of entries below root.
+func wantModes(t *testing.T, root string, want map[string]os.FileMode) {
+	t.Helper()
+	for rel, m := range want {
+		if got := perm(t, root, rel); got != m {
+			t.Errorf("%s: mode %o, want %o", rel, got, m)
+		}
+	}
+}
+
+func TestHandleChmod(t *testing.T) {
+	s, root := newTestServer(t, false)
+	writeFile(t, root, "f", "f", 0o644)
+
+	resp := runChmod(t, s, "path=/f&mode=0600")
+	if resp.Changed != 1 || len(resp.Results) != 1 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if r := resp.Results[0]; r.Path != "/f" || r.Mode != 0o644 || r.NewMode != 0o600 || !r.Owned || resp.AgentUID != os.Geteuid() {
+		t.Errorf("unexpected result %+v", r)
+	}
+	wantModes(t, root, map[string]os.FileMode{"f": 0o600})
+
+	// an unchanged mode is reported, not counted
+	if resp := runChmod(t, s, "path=/f&mode=600"); resp.Changed != 0 || resp.Results[0].Changed {
+		t.Errorf("unchanged mode counted: %+v", resp)
+	}
+
+	// without recursive only the directory itself changes
+	chmodTree(t, root)
+	runChmod(t, s, "path=/d&mode=0750")
+	wantModes(t, root, map[string]os.FileMode{"d": 0o750, "d/a.sh": 0o644})
+
+	// a symlink path within the root changes its target
+	_ = os.Symlink("f", filepath.Join(root, "link"))
+	runChmod(t, s, "path=/link&mode=0640")
+	wantModes(t, root, map[string]os.FileMode{"f": 0o640})
+}
+
+func TestHandleChmodRecursive(t *testing.T) {
+	s, root := newTestServer(t, false)
+	outside := t.TempDir()
+	writeFile(t, outside, "secret", "s", 0o644)
+	chmodTree(t, root)
+	_ = os.Symlink(filepath.Join(outside, "secret"), filepath.Join(root, "d/out"))
+	_ = os.Symlink(outside, filepath.Join(root, "d/sub/outdir"))
+
+	resp := runChmod(t, s, "path=/d&mode=0700&recursive=true")
+	wantModes(t, root, map[string]os.FileMode{"d": 0o700, "d/a.sh": 0o700, "d/sub": 0o700, "d/sub/c.sh": 0o700, "d/skip/e.sh": 0o700})
+	if m := perm(t, outside, "secret"); m != 0o644 {
+		t.Fatalf("file outside the root changed to %o", m)
+	}
+	if m := perm(t, filepath.Dir(outside), filepath.Base(outside)); m == 0o700 {
+		t.Fatal("directory outside the root changed")
+	}
+	skipped := map[string]bool{}
+	for _, r := range resp.Results {
+		if r.Skipped == "symlink" {
+			skipped[r.Path] = true
+		}
+	}
+	if resp.Changed != 7 || !skipped["/d/out"] || !skipped["/d/sub/outdir"] {
+		t.Errorf("changed %d, skipped %v", resp.Changed, skipped)
+	}
+
+	// include leaves path itself; an excluded directory is not descended
+	runChmod(t, s, "path=/d&mode=0755&recursive=true&include=**/*.sh&exclude=skip")
+	wantModes(t, root, map[string]os.FileMode{"d": 0o700, "d/a.sh": 0o755, "d/sub/c.sh": 0o755, "d/skip/e.sh": 0o700, "d/b.txt": 0o700})
+}
+
+func TestHandleChmodRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		readOnly bool
+		pvcDirs  bool
+		query    string
+		want     int
+	}{
+		{name: "read-only", readOnly: true, query: "path=/pvc-a/f&mode=0600", want: http.StatusForbidden},
+		{name: "mode required", query: "path=/pvc-a/f", want: http.StatusBadRequest},
+		{name: "special bits", query: "path=/pvc-a/f&mode=04755", want: http.StatusBadRequest},
+		{name: "invalid glob", query: "path=/pvc-a&mode=0700&recursive=true&include=[", want: http.StatusBadRequest},
+		{name: "missing", query: "path=/pvc-a/nope&mode=0700", want: http.StatusNotFound},
+		{name: "traversal stays in the root", query: "path=/pvc-a/../../../secret&mode=0777", want: http.StatusNotFound},
+		{name: "symlink out of the root", query: "path=/pvc-a/out&mode=0777", want: http.StatusBadRequest},
+		{name: "through a symlink out of the root", query: "path=/pvc-a/outdir/secret&mode=0777", want: http.StatusBadRequest},
+		{name: "root holding the PVCs", pvcDirs: true, query: "path=/&mode=0777&recursive=true", want: http.StatusBadRequest},
+		{name: "traversal back to the root holding the PVCs", pvcDirs: true, query: "path=/pvc-a/..&mode=0777&recursive=true", want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, root := newTestServer(t, tt.readOnly)
+			s.PVCDirs = tt.pvcDirs
+			outside := t.TempDir()
+			writeFile(t, outside, "secret", "s", 0o644)
+			writeFile(t, root, "pvc-a/f", "f", 0o644)
+			writeFile(t, root, "pvc-b/g", "g", 0o644)
+			_ = os.Symlink(filepath.Join(outside, "secret"), filepath.Join(root, "pvc-a/out"))
+			_ = os.Symlink(outside, filepath.Join(root, "pvc-a/outdir"))
+			if rr := do(s, http.MethodPost, "/v1/chmod?"+tt.query); rr.Code != tt.want {
+				t.Fatalf("got %d %q, want %d", rr.Code, rr.Body.String(), tt.want)
+			}
+			wantModes(t, root, map[string]os.FileMode{"pvc-a/f": 0o644, "pvc-b/g": 0o644})
+			wantModes(t, outside, map[string]os.FileMode{"secret": 0o644})
+		})
+	}
+}
+
+// TestChmodEntrySwappedForSymlink changes entries that became symlinks after the walk saw them.
+func TestChmodEntrySwappedForSymlink(t *testing.T) {
+	root, outside := t.TempDir(), t.TempDir()
+	writeFile(t, outside, "secret", "s", 0o644)
+	writeFile(t, root, "f", "f", 0o644)
+	walked, err := os.Lstat(filepath.Join(root, "f"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the file itself is replaced by a symlink out of the root
+	_ = os.Remove(filepath.Join(root, "f"))
+	_ = os.Symlink(filepath.Join(outside, "secret"), filepath.Join(root, "f"))
+	if res := chmodEntry(root, "/f", filepath.Join(root, "f"), walked, 0o777); res.Error == "" || res.Changed {
+		t.Errorf("chmod through a swapped symlink: %+v", res)
+	}
+
+	// a parent directory is replaced by a symlink out of the root
+	_ = os.Symlink(outside, filepath.Join(root, "d"))
+	if res := chmodEntry(root, "/d/secret", filepath.Join(root, "d/secret"), walked, 0o777); res.Error == "" || res.Changed {
+		t.Errorf("chmod through a swapped parent: %+v", res)
+	}
+	if m := perm(t, outside, "secret"); m != 0o644 {
+		t.Fatalf("file outside the root changed to %o", m)
+	}
+
+	writeFile(t, root, "g", "g", 0o644)
+	if res := chmodEntry(root, "/g", filepath.Join(root, "g"), walked, 0o600); !res.Changed || perm(t, root, "g") != 0o600 {
+		t.Errorf("regular file not changed: %+v", res)
+	}
+}
@@ -0,0 +1,362 @@
+package agent
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/fsutil"
+)
+
+// Conflict policies of a copy: what happens to an entry that already exists at the destination.
+const (
+	ConflictSkip      = "skip"
+	ConflictOverwrite = "overwrite"
+	ConflictRename    = "rename"
+)
+
+const (
+	maxCopyJobs   = 4
+	copyJobTTL    = time.Hour
+	maxCopyErrors = 100
+)
+
+// CopyJob is the progress of a server-side copy. Copies run in the background; poll GET /v1/copy?id=.
+type CopyJob struct {
+	ID       string `json:"id"`
+	Path     string `json:"path"`
+	To       string `json:"to"`
+	Conflict string `json:"conflict"`
+	// State is running or done
+	State string `json:"state"`
+	// TotalFiles and TotalBytes are known once the source has been scanned
+	TotalFiles int64 `json:"totalFiles"`
+	TotalBytes int64 `json:"totalBytes"`
+	Files      int64 `json:"files"`
+	Bytes      int64 `json:"bytes"`
+	Skipped    int64 `json:"skipped"`
+	// Failed counts files (non-directories) that were not copied
+	Failed   int64       `json:"failed"`
+	Errors   []CopyError `json:"errors,omitempty"`
+	Started  time.Time   `json:"started"`
+	Finished *time.Time  `json:"finished,omitempty"`
+}
+
+// CopyError is an entry that could not be copied; at most maxCopyErrors are kept.
+type CopyError struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// copyJob guards a running copy's status.
+type copyJob struct {
+	mu       sync.Mutex
+	src, dst string
+	status   CopyJob
+}
+
+func (j *copyJob) snapshot() CopyJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	out := j.status
+	out.Errors = append([]CopyError(nil), j.status.Errors...)
+	return out
+}
+
+func (j *copyJob) update(f func(st *CopyJob)) {
+	j.mu.Lock()
+	f(&j.status)
+	j.mu.Unlock()
+}
+
+// handleCopy starts copying path to `to` and returns the job (202). conflict is skip (default),
+// overwrite or rename; directories present on both sides are merged unless conflict=rename.
+func (s *HTTPServer) handleCopy(w http.ResponseWriter, r *http.Request) {
+	if s.ReadOnly {
+		s.Logger.Warnw("copy in read-only mode")
+		http.Error(w, "read-only", http.StatusForbidden)
+		return
+	}
+	q := r.URL.Query()
+	p, to := q.Get("path"), q.Get("to")
+	conflict := q.Get("conflict")
+	if conflict == "" {
+		conflict = ConflictSkip
+	}
+	if conflict != ConflictSkip && conflict != ConflictOverwrite && conflict != ConflictRename {
+		http.Error(w, "conflict must be skip, overwrite or rename", http.StatusBadRequest)
+		return
+	}
+	src, err := fsutil.JoinSecureNoFollow(s.DataRoot, p)
+	if err != nil {
+		s.Logger.Warnw("join secure failed", "path", p, "error", err)
+		http.Error(w, "bad path", http.StatusBadRequest)
+		return
+	}
+	dst, err := fsutil.JoinSecureNoFollow(s.DataRoot, to)
+	root, _ := fsutil.JoinSecure(s.DataRoot, "/")
+	if err != nil || to == "" || dst == root || s.isPVCDir(root, dst) {
+		s.Logger.Warnw("join secure failed", "to", to, "error", err)
+		http.Error(w, "bad destination", http.StatusBadRequest)
+		return
+	}
+	if s.pvcDir(root, src) != s.pvcDir(root, dst) {
+		http.Error(w, "source and destination are in different PVCs", http.StatusBadRequest)
+		return
+	}
+	fi, err := os.Lstat(src)
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if pfi, err := os.Stat(filepath.Dir(dst)); err != nil || !pfi.IsDir() {
+		http.Error(w, "destination directory not found", http.StatusNotFound)
+		return
+	}
+	if _, err := os.Lstat(dst); err == nil && conflict == ConflictRename {
+		dst = freeName(dst)
+		to = filepath.Join(filepath.Dir(filepath.Clean("/"+to)), filepath.Base(dst))
+	}
+	if src == dst {
+		http.Error(w, "source and destination are the same", http.StatusBadRequest)
+		return
+	}
+	if fi.IsDir() && fsutil.Within(src, dst) {
+		http.Error(w, "cannot copy a directory into itself", http.StatusBadRequest)
+		return
+	}
+
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	job := &copyJob{src: src, dst: dst, status: CopyJob{ID: hex.EncodeToString(id), Path: p, To: to, Conflict: conflict, State: "running", Started: time.Now().UTC()}}
+	s.jobsMu.Lock()
+	running := 0
+	for k, j := range s.jobs {
+		st := j.snapshot()
+		if st.Finished == nil {
+			running++
+		} else if time.Since(*st.Finished) > copyJobTTL {
+			delete(s.jobs, k)
+		}
+	}
+	if running >= maxCopyJobs {
+		s.jobsMu.Unlock()
+		http.Error(w, "too many copies in progress", http.StatusTooManyRequests)
+		return
+	}
+	if s.jobs == nil {
+		s.jobs = map[string]*copyJob{}
+	}
+	s.jobs[job.status.ID] = job
+	s.jobsMu.Unlock()
+
+	s.Logger.Infow("copy started", "id", job.status.ID, "path", p, "to", to, "conflict", conflict)
+	// symlinks must stay inside the PVC being copied
+	linkRoot := root
+	if d := s.pvcDir(root, src); d != "" {
+		linkRoot = filepath.Join(root, d)
+	}
+	go s.runCopy(job, root, linkRoot)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(job.snapshot())
+}
+
+// handleCopyStatus returns the job given by id, or all jobs without id. Only jobs whose source is
+// under path are visible, so a namespace agent keeps the jobs of its PVCs apart.
+func (s *HTTPServer) handleCopyStatus(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	scope, err := fsutil.JoinSecure(s.DataRoot, q.Get("path"))
+	if err != nil {
+		http.Error(w, "bad path", http.StatusBadRequest)
+		return
+	}
+	s.jobsMu.Lock()
+	out := []CopyJob{}
+	for _, j := range s.jobs {
+		if st := j.snapshot(); fsutil.Within(scope, j.src) && (q.Get("id") == "" || st.ID == q.Get("id")) {
+			out = append(out, st)
+		}
+	}
+	s.jobsMu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	if q.Get("id") != "" {
+		if len(out) == 0 {
+			http.Error(w, "unknown copy job", http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(out[0])
+		return
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Started.Before(out[j].Started) })
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+func (s *HTTPServer) runCopy(job *copyJob, root, linkRoot string) {
+	files, size := treeSize(job.src)
+	job.update(func(st *CopyJob) { st.TotalFiles, st.TotalBytes = files, size })
+
+	c := &copier{root: root, linkRoot: linkRoot, conflict: job.status.Conflict, job: job}
+	c.copy(job.src, job.dst)
+
+	now := time.Now().UTC()
+	job.update(func(st *CopyJob) { st.State, st.Finished = "done", &now })
+	st := job.snapshot()
+	s.Logger.Infow("copy finished", "id", st.ID, "files", st.Files, "bytes", st.Bytes, "skipped", st.Skipped, "failed", st.Failed)
+}
+
+type copier struct {
+	// root is the data root errors are reported against; symlinks must resolve inside linkRoot
+	root     string
+	linkRoot string
+	conflict string
+	job      *copyJob
+}
+
+// copy copies src to dst without following symlinks at either side.
+func (c *copier) copy(src, dst string) {
+	fi, err := os.Lstat(src)
+	if err != nil {
+		c.fail(src, err)
+		return
+	}
+	merge := false
+	if dfi, err := os.Lstat(dst); err == nil {
+		switch {
+		case fi.IsDir() && dfi.IsDir() && c.conflict != ConflictRename:
+			merge = true
+		case c.conflict == ConflictSkip:
+			files, _ := treeSize(src)
+			c.job.update(func(j *CopyJob) { j.Skipped += files })
+			return
+		case c.conflict == ConflictOverwrite:
+			// removes a symlink itself, never its target
+			if err := os.RemoveAll(dst); err != nil {
+				c.fail(src, err)
+				return
+			}
+		default:
+			dst = freeName(dst)
+		}
+	}
+	switch {
+	case fi.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			c.fail(src, err)
+			return
+		}
+		if !fsutil.SymlinkWithin(c.linkRoot, src, target) || !fsutil.SymlinkWithin(c.linkRoot, dst, target) {
+			c.fail(src, errors.New("symlink points outside the root"))
+			return
+		}
+		if err := os.Symlink(target, dst); err != nil {
+			c.fail(src, err)
+			return
+		}
+		c.job.update(func(j *CopyJob) { j.Files++ })
+	case fi.IsDir():
+		if !merge {
+			if err := os.Mkdir(dst, fi.Mode().Perm()|0o700); err != nil {
+				// every file below src is lost, the directory itself is not counted
+				files, _ := treeSize(src)
+				c.record(src, err, files)
+				return
+			}
+		}
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			c.record(src, err, 0)
+		}
+		for _, e := range entries {
+			c.copy(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name()))
+		}
+		if !merge {
+			// restore after writing the children
+			if err := os.Chmod(dst, fi.Mode().Perm()); err != nil {
+				c.record(src, err, 0)
+			}
+			_ = os.Chtimes(dst, fi.ModTime(), fi.ModTime())
+		}
+	case fi.Mode().IsRegular():
+		if err := copyFile(src, dst, fi, func(n int64) { c.job.update(func(j *CopyJob) { j.Bytes += n }) }); err != nil {
+			c.fail(src, err)
+			return
+		}
+		c.job.update(func(j *CopyJob) { j.Files++ })
+	default:
+		c.fail(src, fmt.Errorf("unsupported file type %v", fi.Mode().Type()))
+	}
+}
+
+// fail records an error for the file src, reported relative to the root, and counts it as failed.
+func (c *copier) fail(src string, err error) {
+	c.record(src, err, 1)
+}
+
+// record reports err for src and adds failed to the failed files.
+func (c *copier) record(src string, err error, failed int64) {
+	rel, _ := filepath.Rel(c.root, src)
+	c.job.update(func(j *CopyJob) {
+		j.Failed += failed
+		if len(j.Errors) < maxCopyErrors {
+			j.Errors = append(j.Errors, CopyError{Path: "/" + rel, Error: errText(err)})
+		}
+	})
+}
+
+// treeSize counts the non-directory entries below p (or p itself) and the bytes of regular files.
+func treeSize(p string) (files, size int64) {
+	_ = filepath.WalkDir(p, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		files++
+		if d.Type().IsRegular() {
+			if fi, err := d.Info(); err == nil {
+				size += fi.Size()
+			}
+		}
+		return nil
+	})
+	return files, size
+}
+
+// freeName returns the first of "name (1).ext", "name (2).ext", ... that does not exist.
+func freeName(p string) string {
+	dir, base := filepath.Split(p)
+	ext := filepath.Ext(base)
+	if ext == base {
+		ext = ""
+	}
+	stem := strings.TrimSuffix(base, ext)
+	for i := 1; ; i++ {
+		cand := filepath.Join(dir, fmt.Sprintf("%s (%d)%s", stem, i, ext))
+		if _, err := os.Lstat(cand); errors.Is(err, fs.ErrNotExist) {
+			return cand
+		}
+	}
+}
+
+type countingWriter struct {
+	w   io.Writer
+	add func(int64)
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.add(int64(n))
+	return n, err
+}
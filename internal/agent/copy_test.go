@@ -0,0 +1,186 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// runCopyJob starts a copy and waits until it is done.
+func runCopyJob(t *testing.T, s *HTTPServer, query string) CopyJob {
+	t.Helper()
+	rr := do(s, http.MethodPost, "/v1/copy?"+query)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("start copy: got %d %q", rr.Code, rr.Body.String())
+	}
+	var job CopyJob
+	if err := json.Unmarshal(rr.Body.Bytes(), &job); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		rr := do(s, http.MethodGet, "/v1/copy?id="+job.ID)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("copy status: got %d", rr.Code)
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &job); err != nil {
+			t.Fatal(err)
+		}
+		if job.State == "done" {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("copy did not finish")
+	return job
+}
+
+func TestHandleCopy(t *testing.T) {
+	s, root := newTestServer(t, false)
+	writeFile(t, root, "cfg/a.yaml", "aaaa", 0o600)
+	writeFile(t, root, "cfg/sub/b", "bb", 0o640)
+	mtime := time.Unix(1_000_000, 0)
+	_ = os.Chtimes(filepath.Join(root, "cfg/a.yaml"), mtime, mtime)
+	_ = os.Symlink("a.yaml", filepath.Join(root, "cfg/in"))
+	_ = os.Symlink("/etc/passwd", filepath.Join(root, "cfg/out"))
+
+	job := runCopyJob(t, s, "path=/cfg&to=/copy")
+	if job.TotalFiles != 4 || job.Files != 3 || job.Bytes != 6 || job.Failed != 1 || len(job.Errors) != 1 || job.Errors[0].Path != "/cfg/out" {
+		t.Fatalf("unexpected job %+v", job)
+	}
+	fi, err := os.Stat(filepath.Join(root, "copy/a.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o600 || !fi.ModTime().Equal(mtime) {
+		t.Fatalf("mode %v mtime %v not preserved", fi.Mode().Perm(), fi.ModTime())
+	}
+	if l, _ := os.Readlink(filepath.Join(root, "copy/in")); l != "a.yaml" {
+		t.Fatalf("symlink inside the root not copied: %q", l)
+	}
+	if exists(root, "copy/out") {
+		t.Fatal("symlink escaping the root copied")
+	}
+}
+
+func TestHandleCopyConflicts(t *testing.T) {
+	tests := []struct {
+		conflict, wantA, wantTo string
+		wantSkipped             int64
+	}{
+		{conflict: "skip", wantA: "old", wantTo: "/dst", wantSkipped: 1},
+		{conflict: "overwrite", wantA: "new", wantTo: "/dst"},
+		{conflict: "rename", wantA: "old", wantTo: "/dst (1)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.conflict, func(t *testing.T) {
+			s, root := newTestServer(t, false)
+			writeFile(t, root, "src/a", "new", 0o644)
+			writeFile(t, root, "src/b", "b", 0o644)
+			writeFile(t, root, "dst/a", "old", 0o644)
+			job := runCopyJob(t, s, "path=/src&to=/dst&conflict="+tt.conflict)
+			if job.To != tt.wantTo || job.Skipped != tt.wantSkipped {
+				t.Fatalf("to %q skipped %d, want %q %d", job.To, job.Skipped, tt.wantTo, tt.wantSkipped)
+			}
+			if got := readFile(t, root, "dst/a"); got != tt.wantA {
+				t.Fatalf("dst/a = %q, want %q", got, tt.wantA)
+			}
+			if got := readFile(t, root, filepath.Join(tt.wantTo, "b")); got != "b" {
+				t.Fatalf("b not copied: %q", got)
+			}
+		})
+	}
+}
+
+func TestHandleCopyOverwriteReplacesSymlinkNotTarget(t *testing.T) {
+	s, root := newTestServer(t, false)
+	outside := t.TempDir()
+	writeFile(t, outside, "victim", "keep", 0o644)
+	writeFile(t, root, "src/f", "new", 0o644)
+	_ = os.MkdirAll(filepath.Join(root, "dst"), 0o755)
+	_ = os.Symlink(filepath.Join(outside, "victim"), filepath.Join(root, "dst/f"))
+	runCopyJob(t, s, "path=/src&to=/dst&conflict=overwrite")
+	if readFile(t, outside, "victim") != "keep" {
+		t.Fatal("wrote through a symlink")
+	}
+	if readFile(t, root, "dst/f") != "new" {
+		t.Fatal("symlink not replaced")
+	}
+}
+
+func TestHandleCopyMkdirFailureCountedOnce(t *testing.T) {
+	_, root := newTestServer(t, false)
+	writeFile(t, root, "src/sub/a", "a", 0o644)
+	writeFile(t, root, "src/sub/b", "b", 0o644)
+	// the destination's parent is missing, so Mkdir fails
+	c := &copier{root: root, linkRoot: root, conflict: ConflictSkip, job: &copyJob{}}
+	c.copy(filepath.Join(root, "src/sub"), filepath.Join(root, "dst/missing/sub"))
+	st := c.job.snapshot()
+	if st.Failed != 2 || len(st.Errors) != 1 {
+		t.Fatalf("failed %d errors %d, want 2 files and 1 error", st.Failed, len(st.Errors))
+	}
+}
+
+func TestHandleCopyRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		readOnly bool
+		pvcDirs  bool
+		query    string
+		want     int
+	}{
+		{name: "read-only", readOnly: true, query: "path=/pvc-a/src&to=/pvc-a/x", want: http.StatusForbidden},
+		{name: "bad conflict", query: "path=/pvc-a/src&to=/pvc-a/x&conflict=merge", want: http.StatusBadRequest},
+		{name: "into itself", query: "path=/pvc-a/src&to=/pvc-a/src/x", want: http.StatusBadRequest},
+		{name: "same path", query: "path=/pvc-a/src&to=/pvc-a/src", want: http.StatusBadRequest},
+		{name: "missing source", query: "path=/nope&to=/x", want: http.StatusNotFound},
+		{name: "to the root", query: "path=/pvc-a/src&to=/", want: http.StatusBadRequest},
+		{name: "to another PVC", pvcDirs: true, query: "path=/pvc-a/src&to=/pvc-b/src", want: http.StatusBadRequest},
+		{name: "from another PVC", pvcDirs: true, query: "path=/pvc-b&to=/pvc-a/b", want: http.StatusBadRequest},
+		{name: "over a PVC directory", pvcDirs: true, query: "path=/pvc-a/src&to=/../pvc-b&conflict=overwrite", want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, root := newTestServer(t, tt.readOnly)
+			s.PVCDirs = tt.pvcDirs
+			writeFile(t, root, "pvc-a/src/f", "f", 0o644)
+			writeFile(t, root, "pvc-b/g", "g", 0o644)
+			if rr := do(s, http.MethodPost, "/v1/copy?"+tt.query); rr.Code != tt.want {
+				t.Fatalf("got %d %q, want %d", rr.Code, rr.Body.String(), tt.want)
+			}
+			if readFile(t, root, "pvc-b/g") != "g" {
+				t.Fatal("other PVC changed")
+			}
+		})
+	}
+}
+
+func TestCopyStatusScopedToPath(t *testing.T) {
+	s, root := newTestServer(t, false)
+	writeFile(t, root, "pvc-a/f", "f", 0o644)
+	_ = os.Mkdir(filepath.Join(root, "pvc-b"), 0o755)
+	job := runCopyJob(t, s, "path=/pvc-a/f&to=/pvc-a/g")
+	if rr := do(s, http.MethodGet, "/v1/copy?path=/pvc-b&id="+job.ID); rr.Code != http.StatusNotFound {
+		t.Fatalf("job visible from another PVC: %d", rr.Code)
+	}
+	var jobs []CopyJob
+	rr := do(s, http.MethodGet, "/v1/copy?path=/pvc-a")
+	if err := json.Unmarshal(rr.Body.Bytes(), &jobs); err != nil || len(jobs) != 1 {
+		t.Fatalf("jobs %v %v", jobs, err)
+	}
+}
+
+func TestFreeName(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "a.txt", "", 0o644)
+	writeFile(t, root, "a (1).txt", "", 0o644)
+	writeFile(t, root, "dir/x", "", 0o644)
+	for _, c := range []struct{ in, want string }{{"a.txt", "a (2).txt"}, {"dir", "dir (1)"}} {
+		if got := freeName(filepath.Join(root, c.in)); got != filepath.Join(root, c.want) {
+			t.Errorf("freeName(%s) = %s, want %s", c.in, got, c.want)
+		}
+	}
+}
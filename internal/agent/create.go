@@ -0,0 +1,146 @@
+package agent
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/fsutil"
+)
+
+// handleMkdir creates the directory path; parents=true creates missing parents and accepts an
+// existing directory like mkdir -p. mode (octal, default 0755 less umask) applies to path only.
+func (s *HTTPServer) handleMkdir(w http.ResponseWriter, r *http.Request) {
+	if s.ReadOnly {
+		s.Logger.Warnw("mkdir in read-only mode")
+		http.Error(w, "read-only", http.StatusForbidden)
+		return
+	}
+	q := r.URL.Query()
+	p := q.Get("path")
+	parents := q.Get("parents") == "true"
+	mode, hasMode, ok := modeFromQuery(q.Get("mode"), 0o755)
+	if !ok {
+		http.Error(w, "mode must be octal permission bits (e.g. 0750)", http.StatusBadRequest)
+		return
+	}
+	full, ok := s.createPath(w, p)
+	if !ok {
+		return
+	}
+	if fi, err := os.Lstat(full); err == nil {
+		if parents && fi.IsDir() {
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		http.Error(w, "already exists", http.StatusConflict)
+		return
+	}
+	if parents {
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			s.Logger.Warnw("mkdir parents failed", "full", full, "error", err)
+			http.Error(w, "mkdir", http.StatusInternalServerError)
+			return
+		}
+	}
+	s.Logger.Infow("mkdir", "path", p, "parents", parents)
+	if err := os.Mkdir(full, mode); err != nil {
+		s.createError(w, full, err)
+		return
+	}
+	if hasMode {
+		// the create mode is subject to umask
+		_ = os.Chmod(full, mode)
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// handleTouch creates an empty file at path (201) or, if it exists, sets its mtime to now (204).
+// mode (octal, default 0644 less umask) applies to a new file.
+func (s *HTTPServer) handleTouch(w http.ResponseWriter, r *http.Request) {
+	if s.ReadOnly {
+		s.Logger.Warnw("touch in read-only mode")
+		http.Error(w, "read-only", http.StatusForbidden)
+		return
+	}
+	q := r.URL.Query()
+	p := q.Get("path")
+	mode, hasMode, ok := modeFromQuery(q.Get("mode"), 0o644)
+	if !ok {
+		http.Error(w, "mode must be octal permission bits (e.g. 0640)", http.StatusBadRequest)
+		return
+	}
+	full, ok := s.createPath(w, p)
+	if !ok {
+		return
+	}
+	s.Logger.Infow("touch", "path", p)
+	if _, err := os.Lstat(full); err == nil {
+		now := time.Now()
+		// resolve a symlink within the root like other file operations
+		target, err := fsutil.JoinSecure(s.DataRoot, p)
+		if err != nil {
+			http.Error(w, "bad path", http.StatusBadRequest)
+			return
+		}
+		if err := os.Chtimes(target, now, now); err != nil {
+			s.createError(w, full, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	f, err := os.OpenFile(full, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode)
+	if err != nil {
+		s.createError(w, full, err)
+		return
+	}
+	_ = f.Close()
+	if hasMode {
+		_ = os.Chmod(full, mode)
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// createPath resolves the entry to create; the root itself is never created, nor with PVCDirs
+// anything next to the PVC directories.
+func (s *HTTPServer) createPath(w http.ResponseWriter, p string) (string, bool) {
+	full, err := fsutil.JoinSecureNoFollow(s.DataRoot, p)
+	root, _ := fsutil.JoinSecure(s.DataRoot, "/")
+	if err != nil || full == root || s.isPVCDir(root, full) {
+		s.Logger.Warnw("join secure failed", "path", p, "error", err)
+		http.Error(w, "bad path", http.StatusBadRequest)
+		return "", false
+	}
+	return full, true
+}
+
+func (s *HTTPServer) createError(w http.ResponseWriter, full string, err error) {
+	switch {
+	case errors.Is(err, fs.ErrExist):
+		http.Error(w, "already exists", http.StatusConflict)
+	case errors.Is(err, fs.ErrNotExist):
+		http.Error(w, "parent directory not found", http.StatusNotFound)
+	case errors.Is(err, fs.ErrPermission):
+		http.Error(w, "permission denied", http.StatusForbidden)
+	default:
+		s.Logger.Warnw("create failed", "full", full, "error", err)
+		http.Error(w, "create failed", http.StatusInternalServerError)
+	}
+}
+
+// modeFromQuery parses an octal permission mode; only the 0777 bits are accepted.
+func modeFromQuery(v string, def os.FileMode) (mode os.FileMode, set, ok bool) {
+	if v == "" {
+		return def, false, true
+	}
+	n, err := strconv.ParseUint(v, 8, 32)
+	if err != nil || n > 0o777 {
+		return 0, false, false
+	}
+	return os.FileMode(n), true, true
+}
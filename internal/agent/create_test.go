@@ -0,0 +1,150 @@
+package agent
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// perm returns the permission bits of root/rel without following a symlink.
+func perm(t *testing.T, root, rel string) os.FileMode {
+	t.Helper()
+	fi, err := os.Lstat(filepath.Join(root, rel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fi.Mode().Perm()
+}
+
+// post serves POST target and fails unless it answers want.
+func post(t *testing.T, s *HTTPServer, target string, want int) {
+	t.Helper()
+	if rr := do(s, http.MethodPost, target); rr.Code != want {
+		t.Fatalf("%s: got %d %q, want %d", target, rr.Code, rr.Body.String(), want)
+	}
+}
+
+func TestHandleMkdir(t *testing.T) {
+	s, root := newTestServer(t, false)
+
+	post(t, s, "/v1/mkdir?path=/d", http.StatusCreated)
+	if fi, err := os.Stat(filepath.Join(root, "d")); err != nil || !fi.IsDir() {
+		t.Fatal("directory not created")
+	}
+	post(t, s, "/v1/mkdir?path=/d", http.StatusConflict)
+	post(t, s, "/v1/mkdir?path=/d&parents=true", http.StatusCreated)
+
+	// the mode is exact, not reduced by umask, and applies to path only
+	post(t, s, "/v1/mkdir?path=/m&mode=0770", http.StatusCreated)
+	if m := perm(t, root, "m"); m != 0o770 {
+		t.Errorf("mode %o, want 770", m)
+	}
+	post(t, s, "/v1/mkdir?path=/a/b/c", http.StatusNotFound)
+	post(t, s, "/v1/mkdir?path=/a/b/c&parents=true&mode=0700", http.StatusCreated)
+	if perm(t, root, "a/b/c") != 0o700 || perm(t, root, "a") == 0o700 {
+		t.Errorf("modes a %o, a/b/c %o", perm(t, root, "a"), perm(t, root, "a/b/c"))
+	}
+
+	writeFile(t, root, "f", "f", 0o644)
+	post(t, s, "/v1/mkdir?path=/f&parents=true", http.StatusConflict)
+	if readFile(t, root, "f") != "f" {
+		t.Fatal("file replaced by a directory")
+	}
+}
+
+func TestHandleTouch(t *testing.T) {
+	s, root := newTestServer(t, false)
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	touched := func(rel string) bool {
+		fi, err := os.Stat(filepath.Join(root, rel))
+		return err == nil && fi.ModTime().After(old)
+	}
+
+	post(t, s, "/v1/touch?path=/f&mode=0600", http.StatusCreated)
+	if readFile(t, root, "f") != "" || perm(t, root, "f") != 0o600 {
+		t.Fatalf("new file: %q mode %o", readFile(t, root, "f"), perm(t, root, "f"))
+	}
+
+	// an existing entry only gets a new mtime
+	writeFile(t, root, "g", "data", 0o640)
+	_ = os.Mkdir(filepath.Join(root, "d"), 0o755)
+	for _, rel := range []string{"g", "d"} {
+		_ = os.Chtimes(filepath.Join(root, rel), old, old)
+		post(t, s, "/v1/touch?path=/"+rel+"&mode=0600", http.StatusNoContent)
+		if !touched(rel) {
+			t.Errorf("%s: mtime not updated", rel)
+		}
+	}
+	if readFile(t, root, "g") != "data" || perm(t, root, "g") != 0o640 {
+		t.Error("existing file changed")
+	}
+
+	// a symlink within the root touches its target
+	_ = os.Chtimes(filepath.Join(root, "g"), old, old)
+	_ = os.Symlink("g", filepath.Join(root, "link"))
+	post(t, s, "/v1/touch?path=/link", http.StatusNoContent)
+	if !touched("g") {
+		t.Error("symlink target not touched")
+	}
+}
+
+func TestCreateRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		readOnly bool
+		pvcDirs  bool
+		target   string
+		want     int
+	}{
+		{name: "mkdir read-only", readOnly: true, target: "/v1/mkdir?path=/pvc-a/x", want: http.StatusForbidden},
+		{name: "touch read-only", readOnly: true, target: "/v1/touch?path=/pvc-a/x", want: http.StatusForbidden},
+		{name: "mkdir root", target: "/v1/mkdir?path=/", want: http.StatusBadRequest},
+		{name: "touch root", target: "/v1/touch?path=/", want: http.StatusBadRequest},
+		{name: "mkdir bad mode", target: "/v1/mkdir?path=/pvc-a/x&mode=0789", want: http.StatusBadRequest},
+		{name: "mkdir special bits", target: "/v1/mkdir?path=/pvc-a/x&mode=04755", want: http.StatusBadRequest},
+		{name: "touch bad mode", target: "/v1/touch?path=/pvc-a/x&mode=rw", want: http.StatusBadRequest},
+		{name: "mkdir missing parent", target: "/v1/mkdir?path=/no/x", want: http.StatusNotFound},
+		{name: "touch missing parent", target: "/v1/touch?path=/no/x", want: http.StatusNotFound},
+		{name: "mkdir through a symlink out of the root", target: "/v1/mkdir?path=/pvc-a/out/x", want: http.StatusBadRequest},
+		{name: "mkdir parents through a symlink out of the root", target: "/v1/mkdir?path=/pvc-a/out/x/y&parents=true", want: http.StatusBadRequest},
+		{name: "touch a symlink out of the root", target: "/v1/touch?path=/pvc-a/out", want: http.StatusBadRequest},
+		{name: "touch through a symlink out of the root", target: "/v1/touch?path=/pvc-a/out/x", want: http.StatusBadRequest},
+		{name: "mkdir next to the PVCs", pvcDirs: true, target: "/v1/mkdir?path=/pvc-c", want: http.StatusBadRequest},
+		{name: "touch next to the PVCs", pvcDirs: true, target: "/v1/touch?path=/x", want: http.StatusBadRequest},
+		{name: "mkdir traversal back next to the PVCs", pvcDirs: true, target: "/v1/mkdir?path=/pvc-a/../../pvc-c", want: http.StatusBadRequest},
+		{name: "touch a PVC directory", pvcDirs: true, target: "/v1/touch?path=/pvc-b", want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, root := newTestServer(t, tt.readOnly)
+			s.PVCDirs = tt.pvcDirs
+			outside := t.TempDir()
+			writeFile(t, root, "pvc-b/g", "g", 0o644)
+			_ = os.Mkdir(filepath.Join(root, "pvc-a"), 0o755)
+			_ = os.Symlink(outside, filepath.Join(root, "pvc-a/out"))
+			post(t, s, tt.target, tt.want)
+			if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+				t.Fatal("created outside the root")
+			}
+			if entries, _ := os.ReadDir(root); len(entries) != 2 {
+				t.Fatalf("root has %d entries, want pvc-a and pvc-b", len(entries))
+			}
+			if readFile(t, root, "pvc-b/g") != "g" {
+				t.Fatal("other PVC changed")
+			}
+		})
+	}
+}
+
+// TestCreateTraversalStaysInRoot checks that ".." never climbs above the root.
+func TestCreateTraversalStaysInRoot(t *testing.T) {
+	s, root := newTestServer(t, false)
+	parent := filepath.Dir(root)
+	post(t, s, "/v1/mkdir?path=/../../escaped", http.StatusCreated)
+	post(t, s, "/v1/touch?path=/../escaped-file", http.StatusCreated)
+	if !exists(root, "escaped") || !exists(root, "escaped-file") || exists(parent, "escaped") || exists(parent, "escaped-file") {
+		t.Fatal(`".." left the root`)
+	}
+}
@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,6 +10,8 @@ import (
 	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"syscall"
@@ -22,7 +25,15 @@ type HTTPServer struct {
 	Router   *chi.Mux
 	DataRoot string
 	ReadOnly bool
-	Logger   *zap.SugaredLogger
+	// PVCDirs marks every top-level directory as a separate PVC (namespace agents): entries never
+	// move or copy between them and the directories themselves are not replaced
+	PVCDirs bool
+	// Token, when set, must be presented as a bearer token by callers (the backend)
+	Token  string
+	Logger *zap.SugaredLogger
+
+	jobsMu sync.Mutex
+	jobs   map[string]*copyJob
 }
 
 func NewHTTPServer(dataRoot string, readOnly bool) *HTTPServer {
@@ -34,11 +45,35 @@ func NewHTTPServer(dataRoot string, readOnly bool) *HTTPServer {
 }
 
 func (s *HTTPServer) routes() {
+	s.Router.Use(s.authenticate)
 	s.Router.Get("/v1/tree", s.handleTree)
+	s.Router.Get("/v1/stat", s.handleStat)
 	s.Router.Get("/v1/file", s.handleGetFile)
 	s.Router.Delete("/v1/file", s.handleDelete)
 	s.Router.Post("/v1/upload", s.handleUpload)
 	s.Router.Post("/v1/empty", s.handleEmpty)
+	s.Router.Post("/v1/move", s.handleMove)
+	s.Router.Post("/v1/copy", s.handleCopy)
+	s.Router.Get("/v1/copy", s.handleCopyStatus)
+	s.Router.Post("/v1/mkdir", s.handleMkdir)
+	s.Router.Post("/v1/touch", s.handleTouch)
+	s.Router.Post("/v1/chmod", s.handleChmod)
+}
+
+func (s *HTTPServer) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.Token == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(s.Token)) != 1 {
+			s.Logger.Warnw("unauthenticated request rejected", "path", r.URL.Path, "remote", r.RemoteAddr)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
 }
 
 type TreeEntry struct {
@@ -50,6 +85,20 @@ type TreeEntry struct {
 	UID   uint32    `json:"uid"`
 	GID   uint32    `json:"gid"`
 	Mode  uint32    `json:"mode"`
+	// Type is file, dir, symlink, fifo, socket, device, chardevice or irregular; entries are
+	// never followed, so a symlink's Size is the length of its target
+	Type string `json:"type"`
+	// LinkTarget is a symlink's target as stored; LinkInRoot tells whether it resolves inside
+	// the data root and LinkBroken whether that resolution does not exist
+	LinkTarget string `json:"linkTarget,omitempty"`
+	LinkInRoot *bool  `json:"linkInRoot,omitempty"`
+	LinkBroken bool   `json:"linkBroken,omitempty"`
+	Inode      uint64 `json:"inode,omitempty"`
+	Nlink      uint64 `json:"nlink,omitempty"`
+	// Allocated is the disk space in use; less than Size for sparse files
+	Allocated int64      `json:"allocated"`
+	Atime     *time.Time `json:"atime,omitempty"`
+	Ctime     *time.Time `json:"ctime,omitempty"`
 }
 
 func (s *HTTPServer) handleTree(w http.ResponseWriter, r *http.Request) {
@@ -103,30 +152,72 @@ func (s *HTTPServer) handleTree(w http.ResponseWriter, r *http.Request) {
 		end = len(entries)
 	}
 	page := entries[offset:end]
+	root, _ := fsutil.JoinSecure(s.DataRoot, "/")
 	out := make([]TreeEntry, 0, len(page))
 	for _, e := range page {
-		uid, gid, mode := uint32(0), uint32(0), uint32(e.Mode().Perm())
-		if st, ok := e.Sys().(*syscall.Stat_t); ok {
-			uid = st.Uid
-			gid = st.Gid
-			mode = uint32(e.Mode().Perm())
-		}
-		out = append(out, TreeEntry{
-			Name:  e.Name(),
-			Path:  filepath.Join(p, e.Name()),
-			IsDir: e.IsDir(),
-			Size:  e.Size(),
-			Mod:   e.ModTime(),
-			UID:   uid,
-			GID:   gid,
-			Mode:  mode,
-		})
+		out = append(out, treeEntry(root, filepath.Join(p, e.Name()), filepath.Join(full, e.Name()), e))
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("X-Total-Count", strconv.Itoa(len(entries)))
 	_ = json.NewEncoder(w).Encode(out)
 }
 
+// treeEntry describes the entry at full (requested as reqPath) from its Lstat info; symlinks are
+// checked against the resolved data root.
+func treeEntry(root, reqPath, full string, e os.FileInfo) TreeEntry {
+	uid, gid, mode := uint32(0), uint32(0), uint32(e.Mode().Perm())
+	if st, ok := e.Sys().(*syscall.Stat_t); ok {
+		uid = st.Uid
+		gid = st.Gid
+		mode = uint32(e.Mode().Perm())
+	}
+	out := TreeEntry{
+		Name:  e.Name(),
+		Path:  reqPath,
+		IsDir: e.IsDir(),
+		Size:  e.Size(),
+		Mod:   e.ModTime(),
+		UID:   uid,
+		GID:   gid,
+		Mode:  mode,
+		Type:  fileType(e.Mode()),
+	}
+	statExtra(&out, e)
+	if out.Type == "symlink" {
+		if target, err := os.Readlink(full); err == nil {
+			inRoot := fsutil.SymlinkWithin(root, full, target)
+			out.LinkTarget, out.LinkInRoot = target, &inRoot
+			// only resolve links that stay inside the root
+			if inRoot {
+				_, err := os.Stat(full)
+				out.LinkBroken = err != nil
+			}
+		}
+	}
+	return out
+}
+
+func fileType(m os.FileMode) string {
+	switch {
+	case m.IsRegular():
+		return "file"
+	case m.IsDir():
+		return "dir"
+	case m&os.ModeSymlink != 0:
+		return "symlink"
+	case m&os.ModeNamedPipe != 0:
+		return "fifo"
+	case m&os.ModeSocket != 0:
+		return "socket"
+	case m&os.ModeCharDevice != 0:
+		return "chardevice"
+	case m&os.ModeDevice != 0:
+		return "device"
+	default:
+		return "irregular"
+	}
+}
+
 func (s *HTTPServer) handleGetFile(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
 	p := q.Get("path")
@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func newTestServer(t *testing.T, readOnly bool) (*HTTPServer, string) {
+	t.Helper()
+	root := t.TempDir()
+	s := NewHTTPServer(root, readOnly)
+	s.Logger = zap.NewNop().Sugar()
+	return s, root
+}
+
+// do serves method target and returns the recorded response.
+func do(s *HTTPServer, method, target string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	s.Router.ServeHTTP(rr, httptest.NewRequest(method, target, nil))
+	return rr
+}
+
+// writeFile creates root/rel with its parents.
+func writeFile(t *testing.T, root, rel, content string, mode os.FileMode) {
+	t.Helper()
+	full := filepath.Join(root, rel)
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(full, []byte(content), mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, root, rel string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(root, rel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func exists(root, rel string) bool {
+	_, err := os.Lstat(filepath.Join(root, rel))
+	return err == nil
+}
+
+func TestAuthenticate(t *testing.T) {
+	s, _ := newTestServer(t, false)
+	s.Token = "secret"
+	if rr := do(s, http.MethodGet, "/v1/tree?path=/"); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("without token: got %d, want 401", rr.Code)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/v1/tree?path=/", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	s.Router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("with token: got %d, want 200", rr.Code)
+	}
+}
+
+func TestTreeSymlinks(t *testing.T) {
+	s, root := newTestServer(t, false)
+	writeFile(t, root, "d/f", "x", 0o644)
+	for name, target := range map[string]string{"in": "d/f", "broken": "d/missing", "out": "../../etc/passwd", "abs": "/etc/passwd"} {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	type link struct {
+		target         string
+		inRoot, broken bool
+	}
+	want := map[string]link{
+		"abs":    {"/etc/passwd", false, false},
+		"broken": {"d/missing", true, true},
+		"in":     {"d/f", true, false},
+		"out":    {"../../etc/passwd", false, false},
+	}
+
+	rr := do(s, http.MethodGet, "/v1/tree?path=/")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("tree: got %d", rr.Code)
+	}
+	var entries []TreeEntry
+	if err := json.Unmarshal(rr.Body.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	seen := 0
+	for _, e := range entries {
+		w, ok := want[e.Name]
+		if !ok {
+			continue
+		}
+		seen++
+		if e.Type != "symlink" || e.LinkTarget != w.target || e.LinkInRoot == nil || *e.LinkInRoot != w.inRoot || e.LinkBroken != w.broken {
+			t.Errorf("tree %s: got type %s target %q inRoot %v broken %t, want %+v", e.Name, e.Type, e.LinkTarget, e.LinkInRoot, e.LinkBroken, w)
+		}
+	}
+	if seen != len(want) {
+		t.Errorf("tree listed %d of %d symlinks", seen, len(want))
+	}
+
+	for name, w := range want {
+		rr := do(s, http.MethodGet, "/v1/stat?path=/"+name)
+		var e TreeEntry
+		if err := json.Unmarshal(rr.Body.Bytes(), &e); err != nil {
+			t.Fatalf("stat %s: %d %v", name, rr.Code, err)
+		}
+		if e.LinkTarget != w.target || e.LinkInRoot == nil || *e.LinkInRoot != w.inRoot || e.LinkBroken != w.broken {
+			t.Errorf("stat %s: got target %q inRoot %v broken %t, want %+v", name, e.LinkTarget, e.LinkInRoot, e.LinkBroken, w)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package agent
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/fsutil"
+)
+
+// renameEntry is os.Rename; tests replace it to make moves fail.
+var renameEntry = os.Rename
+
+// handleMove renames path to `to`. An existing destination is a conflict unless overwrite=true,
+// which replaces it (directories included) and keeps it if the move fails. Moves across
+// filesystems fall back to copy and delete.
+func (s *HTTPServer) handleMove(w http.ResponseWriter, r *http.Request) {
+	if s.ReadOnly {
+		s.Logger.Warnw("move in read-only mode")
+		http.Error(w, "read-only", http.StatusForbidden)
+		return
+	}
+	q := r.URL.Query()
+	p, to := q.Get("path"), q.Get("to")
+	overwrite := q.Get("overwrite") == "true"
+	src, err := fsutil.JoinSecureNoFollow(s.DataRoot, p)
+	if err != nil {
+		s.Logger.Warnw("join secure failed", "path", p, "error", err)
+		http.Error(w, "bad path", http.StatusBadRequest)
+		return
+	}
+	dst, err := fsutil.JoinSecureNoFollow(s.DataRoot, to)
+	if err != nil || to == "" {
+		s.Logger.Warnw("join secure failed", "to", to, "error", err)
+		http.Error(w, "bad destination", http.StatusBadRequest)
+		return
+	}
+	root, _ := fsutil.JoinSecure(s.DataRoot, "/")
+	if src == root || dst == root || s.isPVCDir(root, src) || s.isPVCDir(root, dst) {
+		http.Error(w, "cannot move the root", http.StatusBadRequest)
+		return
+	}
+	if s.pvcDir(root, src) != s.pvcDir(root, dst) {
+		http.Error(w, "source and destination are in different PVCs", http.StatusBadRequest)
+		return
+	}
+	fi, err := os.Lstat(src)
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if src == dst {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if fi.IsDir() && fsutil.Within(src, dst) {
+		http.Error(w, "cannot move a directory into itself", http.StatusBadRequest)
+		return
+	}
+	if pfi, err := os.Stat(filepath.Dir(dst)); err != nil || !pfi.IsDir() {
+		http.Error(w, "destination directory not found", http.StatusNotFound)
+		return
+	}
+	var backup string
+	if dfi, err := os.Lstat(dst); err == nil {
+		if !overwrite {
+			http.Error(w, "destination exists", http.StatusConflict)
+			return
+		}
+		// rename replaces files atomically; anything else is moved aside first and restored if
+		// the move fails
+		if dfi.IsDir() || fi.IsDir() {
+			if backup, err = moveAside(dst); err != nil {
+				s.Logger.Warnw("move destination aside failed", "dst", dst, "error", err)
+				http.Error(w, "move failed", http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+	s.Logger.Infow("move", "path", p, "to", to, "overwrite", overwrite)
+	copied := false
+	err = renameEntry(src, dst)
+	if errors.Is(err, syscall.EXDEV) {
+		// src and dst are on different filesystems (a directory inside the PVC that is a mount of
+		// its own): copy, then delete the source
+		err = nil
+		if _, lerr := os.Lstat(dst); lerr == nil && backup == "" {
+			// a copy cannot replace the file atomically
+			backup, err = moveAside(dst)
+		}
+		if err == nil {
+			if err = copyTree(src, dst); err != nil {
+				_ = os.RemoveAll(dst)
+			}
+			copied = err == nil
+		}
+	}
+	if err != nil {
+		s.Logger.Warnw("move failed", "src", src, "dst", dst, "error", err)
+		if backup != "" {
+			if rerr := os.Rename(backup, dst); rerr != nil {
+				s.Logger.Errorw("restoring the replaced destination failed", "dst", dst, "backup", backup, "error", rerr)
+			}
+		}
+		http.Error(w, "move failed", http.StatusInternalServerError)
+		return
+	}
+	if backup != "" {
+		if err := os.RemoveAll(backup); err != nil {
+			s.Logger.Warnw("remove replaced destination failed", "backup", backup, "error", err)
+		}
+	}
+	if copied {
+		if err := os.RemoveAll(src); err != nil {
+			s.Logger.Warnw("remove source after copy failed", "src", src, "error", err)
+			http.Error(w, "copied but source not removed", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// moveAside renames p to a hidden sibling and returns its new path.
+func moveAside(p string) (string, error) {
+	id := make([]byte, 6)
+	_, _ = rand.Read(id)
+	backup := filepath.Join(filepath.Dir(p), "."+filepath.Base(p)+".pvc-viewer-replaced-"+hex.EncodeToString(id))
+	if err := os.Rename(p, backup); err != nil {
+		return "", err
+	}
+	return backup, nil
+}
+
+// copyTree copies src to dst keeping modes and mtimes; symlinks are recreated as they are.
+func copyTree(src, dst string) error {
+	fi, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	switch {
+	case fi.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	case fi.IsDir():
+		if err := os.Mkdir(dst, fi.Mode().Perm()|0o700); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := copyTree(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())); err != nil {
+				return err
+			}
+		}
+		// restore after writing the children
+		if err := os.Chmod(dst, fi.Mode().Perm()); err != nil {
+			return err
+		}
+		return os.Chtimes(dst, fi.ModTime(), fi.ModTime())
+	case fi.Mode().IsRegular():
+		return copyFile(src, dst, fi, nil)
+	default:
+		return fmt.Errorf("%s: unsupported file type %v", src, fi.Mode().Type())
+	}
+}
+
+// copyFile copies a regular file to a new dst with fi's mode and mtime; count, when set, is
+// called with the bytes written.
+func copyFile(src, dst string, fi os.FileInfo, count func(int64)) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	var wr io.Writer = out
+	if count != nil {
+		wr = countingWriter{w: out, add: count}
+	}
+	if _, err := io.Copy(wr, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	// the create mode is subject to umask
+	if err := os.Chmod(dst, fi.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, fi.ModTime(), fi.ModTime())
+}
+
+// pvcDir returns the top-level directory holding full with PVCDirs, "" otherwise.
+func (s *HTTPServer) pvcDir(root, full string) string {
+	if !s.PVCDirs {
+		return ""
+	}
+	rel, err := filepath.Rel(root, full)
+	if err != nil {
+		return ""
+	}
+	first, _, _ := strings.Cut(filepath.ToSlash(rel), "/")
+	return first
+}
+
+// isPVCDir reports whether full is a PVC's directory itself (only with PVCDirs).
+func (s *HTTPServer) isPVCDir(root, full string) bool {
+	return s.PVCDirs && filepath.Dir(full) == root
+}
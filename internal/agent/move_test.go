@@ -0,0 +1,202 @@
+package agent
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestHandleMove(t *testing.T) {
+	s, root := newTestServer(t, false)
+	writeFile(t, root, "a.txt", "a", 0o644)
+	post(t, s, "/v1/move?path=/a.txt&to=/b.txt", http.StatusNoContent)
+	if exists(root, "a.txt") || readFile(t, root, "b.txt") != "a" {
+		t.Fatal("file not renamed")
+	}
+
+	writeFile(t, root, "src/x/f", "f", 0o644)
+	_ = os.Mkdir(filepath.Join(root, "dst"), 0o755)
+	post(t, s, "/v1/move?path=/src/x&to=/dst/x", http.StatusNoContent)
+	if readFile(t, root, "dst/x/f") != "f" || exists(root, "src/x") {
+		t.Fatal("directory not moved")
+	}
+
+	// an existing destination needs overwrite; a directory is replaced as a whole
+	writeFile(t, root, "c", "c", 0o644)
+	post(t, s, "/v1/move?path=/c&to=/b.txt", http.StatusConflict)
+	if readFile(t, root, "b.txt") != "a" || !exists(root, "c") {
+		t.Fatal("destination replaced without overwrite")
+	}
+	post(t, s, "/v1/move?path=/c&to=/b.txt&overwrite=true", http.StatusNoContent)
+	if readFile(t, root, "b.txt") != "c" {
+		t.Fatal("file not replaced")
+	}
+	post(t, s, "/v1/move?path=/b.txt&to=/dst&overwrite=true", http.StatusNoContent)
+	if readFile(t, root, "dst") != "c" {
+		t.Fatal("directory not replaced")
+	}
+
+	// a symlink is moved, not its target
+	_ = os.Symlink("dst", filepath.Join(root, "link"))
+	post(t, s, "/v1/move?path=/link&to=/link2", http.StatusNoContent)
+	if l, err := os.Readlink(filepath.Join(root, "link2")); err != nil || l != "dst" || readFile(t, root, "dst") != "c" {
+		t.Fatalf("link not moved: %q %v", l, err)
+	}
+
+	// moving onto itself is a no-op
+	post(t, s, "/v1/move?path=/dst&to=/dst", http.StatusNoContent)
+
+	// within a PVC directory of a namespace agent
+	s.PVCDirs = true
+	writeFile(t, root, "pvc-a/f", "f", 0o644)
+	post(t, s, "/v1/move?path=/pvc-a/f&to=/pvc-a/g", http.StatusNoContent)
+	if readFile(t, root, "pvc-a/g") != "f" {
+		t.Fatal("not moved within the PVC")
+	}
+}
+
+func TestHandleMoveRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		readOnly bool
+		pvcDirs  bool
+		query    string
+		want     int
+	}{
+		{name: "read-only", readOnly: true, query: "path=/pvc-a/f&to=/pvc-a/g", want: http.StatusForbidden},
+		{name: "empty destination", query: "path=/pvc-a/f", want: http.StatusBadRequest},
+		{name: "missing source", query: "path=/pvc-a/nope&to=/pvc-a/g", want: http.StatusNotFound},
+		{name: "missing destination directory", query: "path=/pvc-a/f&to=/pvc-a/no/g", want: http.StatusNotFound},
+		{name: "directory into itself", query: "path=/pvc-a&to=/pvc-a/sub", want: http.StatusBadRequest},
+		{name: "the root", query: "path=/&to=/x", want: http.StatusBadRequest},
+		{name: "onto the root", query: "path=/pvc-a/f&to=/&overwrite=true", want: http.StatusBadRequest},
+		{name: "traversal onto the root", query: "path=/pvc-a/f&to=/pvc-a/../..&overwrite=true", want: http.StatusBadRequest},
+		{name: "source through a symlink out of the root", query: "path=/pvc-a/outdir/secret&to=/pvc-a/s", want: http.StatusBadRequest},
+		{name: "destination through a symlink out of the root", query: "path=/pvc-a/f&to=/pvc-a/outdir/f", want: http.StatusBadRequest},
+		{name: "destination symlink out of the root", query: "path=/pvc-a/f&to=/pvc-a/outdir&overwrite=true", want: http.StatusNoContent},
+		{name: "across PVC directories", pvcDirs: true, query: "path=/pvc-a/f&to=/pvc-b/f", want: http.StatusBadRequest},
+		{name: "traversal across PVC directories", pvcDirs: true, query: "path=/pvc-a/f&to=/pvc-a/../pvc-b/f", want: http.StatusBadRequest},
+		{name: "a PVC directory", pvcDirs: true, query: "path=/pvc-a&to=/pvc-c", want: http.StatusBadRequest},
+		{name: "onto a PVC directory", pvcDirs: true, query: "path=/pvc-a/f&to=/pvc-b&overwrite=true", want: http.StatusBadRequest},
+		{name: "next to the PVC directories", pvcDirs: true, query: "path=/pvc-a/f&to=/f", want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, root := newTestServer(t, tt.readOnly)
+			s.PVCDirs = tt.pvcDirs
+			outside := t.TempDir()
+			writeFile(t, outside, "secret", "s", 0o644)
+			writeFile(t, root, "pvc-a/f", "f", 0o644)
+			writeFile(t, root, "pvc-b/g", "g", 0o644)
+			_ = os.Symlink(outside, filepath.Join(root, "pvc-a/outdir"))
+			post(t, s, "/v1/move?"+tt.query, tt.want)
+			if readFile(t, root, "pvc-b/g") != "g" || readFile(t, outside, "secret") != "s" {
+				t.Fatal("other PVC or outside the root changed")
+			}
+			if entries, _ := os.ReadDir(outside); len(entries) != 1 {
+				t.Fatal("moved out of the root")
+			}
+		})
+	}
+}
+
+func TestCopyTreeKeepsModesAndTimes(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "src/sub/f", "data", 0o600)
+	_ = os.Symlink("f", filepath.Join(root, "src/sub/l"))
+	mtime := time.Unix(1_000_000, 0)
+	_ = os.Chtimes(filepath.Join(root, "src/sub/f"), mtime, mtime)
+	_ = os.Chmod(filepath.Join(root, "src/sub"), 0o750)
+	_ = os.Chtimes(filepath.Join(root, "src/sub"), mtime, mtime)
+
+	if err := copyTree(filepath.Join(root, "src"), filepath.Join(root, "dst")); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		rel  string
+		mode os.FileMode
+	}{{"dst/sub", 0o750}, {"dst/sub/f", 0o600}} {
+		fi, err := os.Stat(filepath.Join(root, c.rel))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != c.mode || !fi.ModTime().Equal(mtime) {
+			t.Errorf("%s: mode %v mtime %v, want %v %v", c.rel, fi.Mode().Perm(), fi.ModTime(), c.mode, mtime)
+		}
+	}
+	if l, _ := os.Readlink(filepath.Join(root, "dst/sub/l")); l != "f" {
+		t.Errorf("symlink target %q, want f", l)
+	}
+}
+
+func TestHandleMoveOverwriteFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		renames error
+		src     bool // src is a directory
+		want    int
+		check   func(t *testing.T, root string)
+	}{
+		{
+			name:    "failed rename restores the destination",
+			renames: syscall.EIO,
+			src:     true,
+			want:    http.StatusInternalServerError,
+			check: func(t *testing.T, root string) {
+				if readFile(t, root, "dst/old") != "old" || readFile(t, root, "src/new") != "new" {
+					t.Fatal("source or destination lost")
+				}
+			},
+		},
+		{
+			name:    "cross-device directory",
+			renames: syscall.EXDEV,
+			src:     true,
+			want:    http.StatusNoContent,
+			check: func(t *testing.T, root string) {
+				if readFile(t, root, "dst/new") != "new" || exists(root, "dst/old") || exists(root, "src") {
+					t.Fatal("directory not moved by copy")
+				}
+			},
+		},
+		{
+			name:    "cross-device file",
+			renames: syscall.EXDEV,
+			want:    http.StatusNoContent,
+			check: func(t *testing.T, root string) {
+				if readFile(t, root, "dst") != "new" || exists(root, "src") {
+					t.Fatal("file not moved by copy")
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, root := newTestServer(t, false)
+			if tt.src {
+				writeFile(t, root, "src/new", "new", 0o644)
+				writeFile(t, root, "dst/old", "old", 0o644)
+			} else {
+				writeFile(t, root, "src", "new", 0o644)
+				writeFile(t, root, "dst", "old", 0o644)
+			}
+			renameEntry = func(string, string) error { return &os.LinkError{Op: "rename", Err: tt.renames} }
+			defer func() { renameEntry = os.Rename }()
+
+			rr := do(s, http.MethodPost, "/v1/move?path=/src&to=/dst&overwrite=true")
+			if rr.Code != tt.want {
+				t.Fatalf("got %d %q, want %d", rr.Code, rr.Body.String(), tt.want)
+			}
+			tt.check(t, root)
+			entries, _ := os.ReadDir(root)
+			for _, e := range entries {
+				if e.Name() != "src" && e.Name() != "dst" {
+					t.Errorf("left %s behind", e.Name())
+				}
+			}
+		})
+	}
+}
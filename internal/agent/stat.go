@@ -0,0 +1,29 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/fsutil"
+)
+
+// handleStat describes path itself like a tree entry; a symlink is reported, not followed.
+func (s *HTTPServer) handleStat(w http.ResponseWriter, r *http.Request) {
+	p := r.URL.Query().Get("path")
+	full, err := fsutil.JoinSecureNoFollow(s.DataRoot, p)
+	if err != nil {
+		s.Logger.Warnw("join secure failed", "path", p, "error", err)
+		http.Error(w, "bad path", http.StatusBadRequest)
+		return
+	}
+	fi, err := os.Lstat(full)
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	root, _ := fsutil.JoinSecure(s.DataRoot, "/")
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(treeEntry(root, filepath.Join("/", p), full, fi))
+}
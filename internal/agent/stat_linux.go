@@ -0,0 +1,18 @@
+package agent
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// statExtra fills the inode fields of e from fi.
+func statExtra(e *TreeEntry, fi os.FileInfo) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	atime, ctime := time.Unix(st.Atim.Unix()), time.Unix(st.Ctim.Unix())
+	e.Inode, e.Nlink, e.Allocated = st.Ino, uint64(st.Nlink), int64(st.Blocks)*512
+	e.Atime, e.Ctime = &atime, &ctime
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package agent
+
+import "os"
+
+// statExtra is a no-op where Stat_t differs; agents run on Linux.
+func statExtra(e *TreeEntry, fi os.FileInfo) {}
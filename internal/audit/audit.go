@@ -0,0 +1,173 @@
+package audit
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/auth"
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
+)
+
+// Event is one audited data operation.
+type Event struct {
+	Time       time.Time `json:"time"`
+	RequestID  string    `json:"requestID,omitempty"`
+	User       string    `json:"user"`
+	Groups     []string  `json:"groups,omitempty"`
+	Action     string    `json:"action"`
+	Namespace  string    `json:"namespace"`
+	PVC        string    `json:"pvc"`
+	Path       string    `json:"path"`
+	Target     string    `json:"target,omitempty"`
+	Method     string    `json:"method"`
+	Status     int       `json:"status"`
+	BytesIn    int64     `json:"bytesIn"`
+	BytesOut   int64     `json:"bytesOut"`
+	DurationMs int64     `json:"durationMs"`
+	RemoteAddr string    `json:"remoteAddr,omitempty"`
+}
+
+// Sink persists audit events.
+type Sink interface {
+	Name() string
+	Write(Event) error
+	Close() error
+}
+
+var sinkErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "pvc_viewer_audit_sink_errors_total",
+	Help: "Audit events that could not be written, by sink.",
+}, []string{"sink"})
+
+// Logger fans events out to the configured sinks. Sinks are replaced on config reload.
+type Logger struct {
+	Logger *zap.SugaredLogger
+
+	mu    sync.RWMutex
+	sinks []Sink
+	specs []config.AuditSink
+}
+
+func NewLogger(logger *zap.SugaredLogger) *Logger { return &Logger{Logger: logger} }
+
+// Apply rebuilds sinks from specs; unchanged specs keep their sinks. Sinks that fail to open
+// are skipped with a warning.
+func (l *Logger) Apply(specs []config.AuditSink) {
+	l.mu.RLock()
+	same := specsEqual(l.specs, specs)
+	l.mu.RUnlock()
+	if same {
+		return
+	}
+	sinks := make([]Sink, 0, len(specs))
+	for _, sp := range specs {
+		s, err := NewSink(sp, l.Logger)
+		if err != nil {
+			l.Logger.Warnw("audit sink disabled", "type", sp.Type, "error", err)
+			continue
+		}
+		sinks = append(sinks, s)
+	}
+	l.mu.Lock()
+	old := l.sinks
+	l.sinks, l.specs = sinks, specs
+	l.mu.Unlock()
+	for _, s := range old {
+		_ = s.Close()
+	}
+}
+
+func (l *Logger) Emit(ev Event) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, s := range l.sinks {
+		if err := s.Write(ev); err != nil {
+			sinkErrors.WithLabelValues(s.Name()).Inc()
+			l.Logger.Warnw("audit write failed", "sink", s.Name(), "error", err)
+		}
+	}
+}
+
+func (l *Logger) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, s := range l.sinks {
+		_ = s.Close()
+	}
+	l.sinks, l.specs = nil, nil
+}
+
+// Middleware records an Event for every request handled by next. It should wrap the
+// authorization check so that denied attempts are audited as well.
+func (l *Logger) Middleware(action string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		body := &countingReader{ReadCloser: r.Body}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = body
+		}
+		next(ww, r)
+
+		q := r.URL.Query()
+		ev := Event{
+			Time:       start.UTC(),
+			RequestID:  middleware.GetReqID(r.Context()),
+			User:       "anonymous",
+			Action:     action,
+			Namespace:  q.Get("ns"),
+			PVC:        q.Get("pvc"),
+			Path:       q.Get("path"),
+			Target:     q.Get("to"),
+			Method:     r.Method,
+			Status:     ww.Status(),
+			BytesIn:    body.n,
+			BytesOut:   int64(ww.BytesWritten()),
+			DurationMs: time.Since(start).Milliseconds(),
+			RemoteAddr: r.RemoteAddr,
+		}
+		if ev.Status == 0 {
+			ev.Status = http.StatusOK
+		}
+		if id, ok := auth.FromContext(r.Context()); ok {
+			ev.User, ev.Groups = id.Username, id.Groups
+		}
+		l.Emit(ev)
+	}
+}
+
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func specsEqual(a, b []config.AuditSink) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		x, y := a[i], b[i]
+		if x.Type != y.Type || x.Path != y.Path || x.MaxSizeMB != y.MaxSizeMB || x.MaxBackups != y.MaxBackups || x.URL != y.URL || x.Timeout != y.Timeout || len(x.Headers) != len(y.Headers) {
+			return false
+		}
+		for k, v := range x.Headers {
+			if y.Headers[k] != v {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/auth"
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
+)
+
+// fileLogger returns a Logger writing to a file sink and a function reading its events back.
+func fileLogger(t *testing.T) (*Logger, func() []Event) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l := NewLogger(zap.NewNop().Sugar())
+	l.Apply([]config.AuditSink{{Type: "file", Path: path}})
+	t.Cleanup(l.Close)
+	return l, func() []Event {
+		t.Helper()
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		var out []Event
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			var ev Event
+			if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+				t.Fatalf("bad audit line %q: %v", sc.Text(), err)
+			}
+			out = append(out, ev)
+		}
+		return out
+	}
+}
+
+// serve runs h for a request as id (nil for anonymous).
+func serve(h http.HandlerFunc, method, target, body string, id *auth.Identity) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if id != nil {
+		req = req.WithContext(auth.WithIdentity(req.Context(), id))
+	}
+	rr := httptest.NewRecorder()
+	h(rr, req)
+	return rr
+}
+
+func TestMiddlewareRecordsEvent(t *testing.T) {
+	l, events := fileLogger(t)
+	h := l.Middleware("upload", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = r.Body.Read(make([]byte, 64))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("ok"))
+	})
+	alice := &auth.Identity{Username: "alice", Groups: []string{"team-a"}}
+	serve(h, http.MethodPost, "/api/v1/upload?ns=team-a&pvc=data&path=/dir/report.csv", "hello", alice)
+	serve(h, http.MethodPost, "/api/v1/upload?ns=team-a&pvc=data&path=/x", "", nil)
+
+	got := events()
+	if len(got) != 2 {
+		t.Fatalf("%d events, want 2", len(got))
+	}
+	ev := got[0]
+	if ev.User != "alice" || len(ev.Groups) != 1 || ev.Action != "upload" || ev.Namespace != "team-a" || ev.PVC != "data" ||
+		ev.Path != "/dir/report.csv" || ev.Method != http.MethodPost || ev.Status != http.StatusCreated || ev.BytesIn != 5 || ev.BytesOut != 2 {
+		t.Errorf("unexpected event %+v", ev)
+	}
+	if got[1].User != "anonymous" {
+		t.Errorf("anonymous caller recorded as %q", got[1].User)
+	}
+}
+
+func TestMiddlewareRecordsDenied(t *testing.T) {
+	l, events := fileLogger(t)
+	rbac := auth.NewRBAC([]config.RBACRule{{Users: []string{"alice"}, Namespaces: []string{"team-a"}, PVCs: []string{"*"}, Verbs: []string{"list"}}})
+	called := false
+	h := l.Middleware("delete", auth.Require(func() auth.Authorizer { return rbac }, auth.VerbDelete, func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	rr := serve(h, http.MethodDelete, "/api/v1/file?ns=team-a&pvc=data&path=/keep", "", &auth.Identity{Username: "alice"})
+	if rr.Code != http.StatusForbidden || called {
+		t.Fatalf("got %d, handler called %t; want 403 without the handler", rr.Code, called)
+	}
+	got := events()
+	if len(got) != 1 {
+		t.Fatalf("%d events, want the denied attempt", len(got))
+	}
+	if ev := got[0]; ev.User != "alice" || ev.Action != "delete" || ev.Path != "/keep" || ev.Status != http.StatusForbidden {
+		t.Errorf("unexpected event %+v", ev)
+	}
+}
@@ -0,0 +1,239 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
+)
+
+// NewSink builds a sink from its config.
+func NewSink(spec config.AuditSink, logger *zap.SugaredLogger) (Sink, error) {
+	switch spec.Type {
+	case "stdout":
+		return &jsonLines{name: "stdout", w: os.Stdout}, nil
+	case "file":
+		if spec.Path == "" {
+			return nil, fmt.Errorf("audit file sink requires path")
+		}
+		f, err := openAppend(spec.Path)
+		if err != nil {
+			return nil, err
+		}
+		return &jsonLines{name: "file", w: f, c: f}, nil
+	case "rotating-file":
+		return newRotatingFile(spec)
+	case "webhook":
+		return newWebhook(spec, logger)
+	default:
+		return nil, fmt.Errorf("unknown audit sink type %q", spec.Type)
+	}
+}
+
+// jsonLines writes one JSON object per line.
+type jsonLines struct {
+	name string
+	mu   sync.Mutex
+	w    io.Writer
+	c    io.Closer
+}
+
+func (j *jsonLines) Name() string { return j.name }
+
+func (j *jsonLines) Write(ev Event) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, err = j.w.Write(append(b, '\n'))
+	return err
+}
+
+func (j *jsonLines) Close() error {
+	if j.c != nil {
+		return j.c.Close()
+	}
+	return nil
+}
+
+func openAppend(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+}
+
+// rotatingFile writes JSON lines to path and rotates to path.1 .. path.N when maxSize is exceeded.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+func newRotatingFile(spec config.AuditSink) (*rotatingFile, error) {
+	if spec.Path == "" {
+		return nil, fmt.Errorf("audit rotating-file sink requires path")
+	}
+	r := &rotatingFile{path: spec.Path, maxSize: int64(spec.MaxSizeMB) << 20, maxBackups: spec.MaxBackups}
+	if r.maxSize <= 0 {
+		r.maxSize = 100 << 20
+	}
+	if r.maxBackups <= 0 {
+		r.maxBackups = 5
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := openAppend(r.path)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	r.f, r.size = f, fi.Size()
+	return nil
+}
+
+func (r *rotatingFile) rotate() error {
+	_ = r.f.Close()
+	_ = os.Remove(fmt.Sprintf("%s.%d", r.path, r.maxBackups))
+	for i := r.maxBackups - 1; i >= 1; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	if err := os.Rename(r.path, r.path+".1"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) Name() string { return "rotating-file" }
+
+func (r *rotatingFile) Write(ev Event) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.size > 0 && r.size+int64(len(b)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := r.f.Write(b)
+	r.size += int64(n)
+	return err
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
+
+// webhook POSTs each event as JSON. Delivery is asynchronous so slow receivers never delay
+// file operations; events are dropped (and counted) when the buffer is full.
+type webhook struct {
+	url     string
+	headers map[string]string
+	http    *http.Client
+	logger  *zap.SugaredLogger
+
+	ch   chan Event
+	done chan struct{}
+}
+
+const webhookBuffer = 1024
+
+func newWebhook(spec config.AuditSink, logger *zap.SugaredLogger) (*webhook, error) {
+	if spec.URL == "" {
+		return nil, fmt.Errorf("audit webhook sink requires url")
+	}
+	timeout := spec.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	wh := &webhook{url: spec.URL, headers: spec.Headers, http: &http.Client{Timeout: timeout}, logger: logger, ch: make(chan Event, webhookBuffer), done: make(chan struct{})}
+	go wh.run()
+	return wh, nil
+}
+
+func (w *webhook) Name() string { return "webhook" }
+
+func (w *webhook) Write(ev Event) error {
+	select {
+	case w.ch <- ev:
+		return nil
+	default:
+		return fmt.Errorf("webhook buffer full, event dropped")
+	}
+}
+
+func (w *webhook) run() {
+	defer close(w.done)
+	for ev := range w.ch {
+		if err := w.post(ev); err != nil {
+			sinkErrors.WithLabelValues(w.Name()).Inc()
+			w.logger.Warnw("audit webhook delivery failed", "error", err)
+		}
+	}
+}
+
+func (w *webhook) post(ev Event) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, w.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := w.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Close stops accepting events and waits briefly for queued ones to be delivered.
+func (w *webhook) Close() error {
+	close(w.ch)
+	select {
+	case <-w.done:
+	case <-time.After(5 * time.Second):
+	}
+	return nil
+}
@@ -1,42 +1,148 @@
 package auth
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"net/url"
 	"strings"
+
+	"go.uber.org/zap"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
 )
 
-// Simple RBAC based on allowlists of namespace/PVC glob patterns
-type Rule struct {
-	Namespaces []string
-	PVCs       []string
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Identity is the verified caller attached to the request context.
+type Identity struct {
+	Subject  string   `json:"subject"`
+	Username string   `json:"username"`
+	Groups   []string `json:"groups"`
+}
+
+type ctxKey struct{}
+
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the caller identity, if the request was authenticated.
+func FromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(ctxKey{}).(*Identity)
+	return id, ok && id != nil
 }
 
-type RBAC struct{ Rules []Rule }
+// TokenAuthenticator validates a bearer token and returns the caller identity.
+type TokenAuthenticator interface {
+	AuthenticateToken(ctx context.Context, token string) (*Identity, error)
+}
+
+// Authenticator resolves the caller from a bearer token (tried against each TokenAuthenticator
+// in order) or from a session cookie issued by the OIDC login flow.
+type Authenticator struct {
+	Tokens   []TokenAuthenticator
+	Sessions *SessionManager
+	Login    *OIDC // nil when browser login is not configured
+	Logger   *zap.SugaredLogger
+}
 
-type Matcher interface{ Match(string) bool }
+// NewAuthenticator builds the authenticator chain from spec. clientSecret and sessionKey come
+// from the environment; an empty sessionKey yields per-replica sessions.
+func NewAuthenticator(ctx context.Context, spec config.AuthSpec, client kubernetes.Interface, clientSecret string, sessionKey []byte, logger *zap.SugaredLogger) (*Authenticator, error) {
+	if len(sessionKey) == 0 {
+		logger.Warnw("no session key configured; sessions are not shared between replicas")
+	}
+	a := &Authenticator{Sessions: NewSessionManager(spec.Session.CookieName, spec.Session.TTL, sessionKey), Logger: logger}
+	if spec.OIDC.IssuerURL != "" {
+		o, err := NewOIDC(ctx, spec.OIDC, clientSecret, a.Sessions, logger)
+		if err != nil {
+			return nil, err
+		}
+		a.Tokens = append(a.Tokens, o)
+		if o.oauth2 != nil {
+			a.Login = o
+		}
+	}
+	if spec.ServiceAccounts.Enabled {
+		tr, err := NewTokenReviewAuthenticator(client, spec.ServiceAccounts)
+		if err != nil {
+			return nil, err
+		}
+		a.Tokens = append(a.Tokens, tr)
+	}
+	if len(a.Tokens) == 0 {
+		return nil, errors.New("auth enabled but no authenticator configured")
+	}
+	return a, nil
+}
 
-func (r *RBAC) Allowed(ns, pvc string, nsMatch func([]string) bool, pvcMatch func([]string) bool) bool {
-	if len(r.Rules) == 0 {
-		return true
+func (a *Authenticator) authenticate(r *http.Request) (*Identity, error) {
+	if authz := r.Header.Get("Authorization"); authz != "" {
+		scheme, token, ok := strings.Cut(strings.TrimSpace(authz), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+			return nil, ErrUnauthenticated
+		}
+		token = strings.TrimSpace(token)
+		err := ErrUnauthenticated
+		for _, ta := range a.Tokens {
+			id, terr := ta.AuthenticateToken(r.Context(), token)
+			if terr == nil {
+				return id, nil
+			}
+			err = terr
+		}
+		return nil, err
 	}
-	for _, rule := range r.Rules {
-		if nsMatch(rule.Namespaces) && pvcMatch(rule.PVCs) {
-			return true
+	if a.Sessions != nil {
+		if id, ok := a.Sessions.Read(r); ok {
+			return id, nil
 		}
 	}
-	return false
+	return nil, ErrUnauthenticated
+}
+
+// Middleware rejects unauthenticated API requests with 401 and attaches the identity to the context.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := a.authenticate(r)
+		if err != nil {
+			a.Logger.Infow("authentication failed", "path", r.URL.Path, "error", err)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="pvc-viewer"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), id)))
+	})
 }
 
-// Middleware extracts Bearer or Basic (username) and attaches subject to context (simplified)
-func Middleware(next http.Handler) http.Handler {
+// RequireLogin redirects browsers without a valid session to the login flow (used for the UI).
+func (a *Authenticator) RequireLogin(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authz := r.Header.Get("Authorization")
-		if authz == "" {
+		if a.Login == nil {
 			next.ServeHTTP(w, r)
 			return
 		}
-		_ = strings.TrimSpace(authz) // placeholder; in real impl validate token
+		if _, err := a.authenticate(r); err != nil {
+			http.Redirect(w, r, "/auth/login?rd="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+// RegisterRoutes mounts /auth/login, /auth/callback and /auth/logout when browser login is configured.
+func (a *Authenticator) RegisterRoutes(mux interface {
+	Get(string, http.HandlerFunc)
+}) {
+	if a.Login == nil {
+		return
+	}
+	mux.Get("/auth/login", a.Login.handleLogin)
+	mux.Get("/auth/callback", a.Login.handleCallback)
+	mux.Get("/auth/logout", func(w http.ResponseWriter, r *http.Request) {
+		a.Sessions.Clear(w, r)
+		http.Redirect(w, r, "/", http.StatusFound)
+	})
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"context"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/kube"
+)
+
+// KubeAuthorizer delegates decisions to the API server via SubjectAccessReview, so namespace
+// RoleBindings on persistentvolumeclaims govern access to PVC contents:
+// list/download require "get" on the PVC, upload/delete/empty require "update",
+// admin requires cluster-wide "delete" on pods. An empty pvc is checked without a resource name,
+// i.e. on every PVC of the namespace.
+type KubeAuthorizer struct {
+	Reviewer *kube.AccessReviewer
+}
+
+func (k *KubeAuthorizer) Authorize(ctx context.Context, id *Identity, verb Verb, ns, pvc string) (bool, error) {
+	user, groups := "system:anonymous", []string{"system:unauthenticated"}
+	if id != nil {
+		user, groups = id.Username, append(append([]string{}, id.Groups...), "system:authenticated")
+	}
+	attrs := authorizationv1.ResourceAttributes{Namespace: ns, Resource: "persistentvolumeclaims", Name: pvc}
+	switch verb {
+	case VerbList, VerbDownload:
+		attrs.Verb = "get"
+	case VerbUpload, VerbDelete, VerbEmpty:
+		attrs.Verb = "update"
+	case VerbAdmin:
+		attrs = authorizationv1.ResourceAttributes{Verb: "delete", Resource: "pods"}
+	default:
+		return false, nil
+	}
+	return k.Reviewer.Allowed(ctx, user, groups, attrs)
+}
@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/kube"
+)
+
+func TestKubeAuthorizer(t *testing.T) {
+	alice := &Identity{Username: "alice", Groups: []string{"team-a"}}
+	tests := []struct {
+		name       string
+		id         *Identity
+		verb       Verb
+		sarErr     error
+		wantAttrs  *authorizationv1.ResourceAttributes
+		wantUser   string
+		wantGroups []string
+		want       bool
+		wantErr    bool
+	}{
+		{name: "list is get", id: alice, verb: VerbList, want: true,
+			wantAttrs: &authorizationv1.ResourceAttributes{Namespace: "a", Resource: "persistentvolumeclaims", Name: "p", Verb: "get"},
+			wantUser:  "alice", wantGroups: []string{"team-a", "system:authenticated"}},
+		{name: "download is get", id: alice, verb: VerbDownload, want: true,
+			wantAttrs: &authorizationv1.ResourceAttributes{Namespace: "a", Resource: "persistentvolumeclaims", Name: "p", Verb: "get"}},
+		{name: "upload is update", id: alice, verb: VerbUpload, want: true,
+			wantAttrs: &authorizationv1.ResourceAttributes{Namespace: "a", Resource: "persistentvolumeclaims", Name: "p", Verb: "update"}},
+		{name: "delete is update", id: alice, verb: VerbDelete, want: true,
+			wantAttrs: &authorizationv1.ResourceAttributes{Namespace: "a", Resource: "persistentvolumeclaims", Name: "p", Verb: "update"}},
+		{name: "empty is update", id: alice, verb: VerbEmpty, want: true,
+			wantAttrs: &authorizationv1.ResourceAttributes{Namespace: "a", Resource: "persistentvolumeclaims", Name: "p", Verb: "update"}},
+		{name: "admin is cluster-wide pod delete", id: alice, verb: VerbAdmin, want: true,
+			wantAttrs: &authorizationv1.ResourceAttributes{Resource: "pods", Verb: "delete"}},
+		{name: "anonymous", verb: VerbList, want: true,
+			wantAttrs: &authorizationv1.ResourceAttributes{Namespace: "a", Resource: "persistentvolumeclaims", Name: "p", Verb: "get"},
+			wantUser:  "system:anonymous", wantGroups: []string{"system:unauthenticated"}},
+		{name: "unknown verb denied without review", id: alice, verb: Verb("chown")},
+		{name: "review error fails closed", id: alice, verb: VerbList, sarErr: errors.New("forbidden"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *authorizationv1.SubjectAccessReviewSpec
+			client := fake.NewSimpleClientset()
+			client.PrependReactor("create", "subjectaccessreviews", func(a k8stesting.Action) (bool, runtime.Object, error) {
+				if tt.sarErr != nil {
+					return true, nil, tt.sarErr
+				}
+				sar := a.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+				got = &sar.Spec
+				sar.Status.Allowed = true
+				return true, sar, nil
+			})
+			k := &KubeAuthorizer{Reviewer: kube.NewAccessReviewer(client, time.Minute)}
+			ok, err := k.Authorize(context.Background(), tt.id, tt.verb, "a", "p")
+			if ok != tt.want || (err != nil) != tt.wantErr {
+				t.Fatalf("Authorize = %v, %v; want %v", ok, err, tt.want)
+			}
+			if tt.wantAttrs == nil {
+				if got != nil {
+					t.Errorf("unexpected review %+v", got)
+				}
+				return
+			}
+			if got == nil || *got.ResourceAttributes != *tt.wantAttrs {
+				t.Fatalf("review %+v, want attributes %+v", got, tt.wantAttrs)
+			}
+			if tt.wantUser != "" && (got.User != tt.wantUser || len(got.Groups) != len(tt.wantGroups)) {
+				t.Errorf("review for %q %v, want %q %v", got.User, got.Groups, tt.wantUser, tt.wantGroups)
+			}
+			for i := range tt.wantGroups {
+				if got.Groups[i] != tt.wantGroups[i] {
+					t.Errorf("groups %v, want %v", got.Groups, tt.wantGroups)
+				}
+			}
+		})
+	}
+}
+
+type errAuthorizer struct{}
+
+func (errAuthorizer) Authorize(context.Context, *Identity, Verb, string, string) (bool, error) {
+	return true, errors.New("review failed")
+}
+
+func TestRequireFailsClosed(t *testing.T) {
+	called := false
+	h := Require(func() Authorizer { return errAuthorizer{} }, VerbList, func(http.ResponseWriter, *http.Request) { called = true })
+	rr := httptest.NewRecorder()
+	h(rr, httptest.NewRequest(http.MethodGet, "/api/v1/tree?ns=a&pvc=p", nil))
+	if called || rr.Code != http.StatusInternalServerError {
+		t.Fatalf("handler called %v, status %d", called, rr.Code)
+	}
+}
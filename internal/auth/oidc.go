@@ -0,0 +1,222 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
+)
+
+const stateCookie = "pvc_viewer_oidc_state"
+
+// OIDC validates JWTs issued by the configured issuer (bearer tokens and login ID tokens)
+// and implements the authorization-code login flow for the embedded UI.
+type OIDC struct {
+	verifier  *oidc.IDTokenVerifier
+	clientID  string
+	audiences []string
+	mapping   config.ClaimMapping
+	oauth2    *oauth2.Config // nil when redirectURL is not configured
+	sessions  *SessionManager
+	logger    *zap.SugaredLogger
+}
+
+// NewOIDC builds the verifier from spec. Issuer discovery is only required for the login flow
+// or when jwksURL is not set. ctx bounds background JWKS refreshes.
+func NewOIDC(ctx context.Context, spec config.OIDCSpec, clientSecret string, sessions *SessionManager, logger *zap.SugaredLogger) (*OIDC, error) {
+	if spec.IssuerURL == "" {
+		return nil, errors.New("oidc: issuerURL is required")
+	}
+	o := &OIDC{clientID: spec.ClientID, audiences: spec.Audiences, mapping: spec.ClaimMapping, sessions: sessions, logger: logger}
+	if len(o.audiences) == 0 && spec.ClientID != "" {
+		o.audiences = []string{spec.ClientID}
+	}
+	if len(o.audiences) == 0 {
+		// without an audience any token of the issuer, whatever client it was minted for, would pass
+		return nil, errors.New("oidc: clientID or audiences is required")
+	}
+
+	var provider *oidc.Provider
+	if spec.JWKSURL == "" || spec.RedirectURL != "" {
+		p, err := oidc.NewProvider(ctx, spec.IssuerURL)
+		if err != nil {
+			return nil, fmt.Errorf("oidc discovery: %w", err)
+		}
+		provider = p
+	}
+	// go-oidc only checks for a single client ID; the audience is checked against the
+	// configured list in AuthenticateToken (and against clientID for login ID tokens)
+	vcfg := &oidc.Config{SkipClientIDCheck: true}
+	if spec.JWKSURL != "" {
+		o.verifier = oidc.NewVerifier(spec.IssuerURL, oidc.NewRemoteKeySet(ctx, spec.JWKSURL), vcfg)
+	} else {
+		o.verifier = provider.Verifier(vcfg)
+	}
+
+	if spec.RedirectURL != "" {
+		if spec.ClientID == "" {
+			return nil, errors.New("oidc: clientID is required for login")
+		}
+		scopes := spec.Scopes
+		if len(scopes) == 0 {
+			scopes = []string{oidc.ScopeOpenID, "profile", "email"}
+		}
+		o.oauth2 = &oauth2.Config{
+			ClientID:     spec.ClientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  spec.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       scopes,
+		}
+	}
+	return o, nil
+}
+
+// AuthenticateToken verifies signature, issuer, expiry and audience of a bearer JWT.
+func (o *OIDC) AuthenticateToken(ctx context.Context, raw string) (*Identity, error) {
+	tok, err := o.verifier.Verify(ctx, raw)
+	if err != nil {
+		return nil, err
+	}
+	if !intersects(tok.Audience, o.audiences) {
+		return nil, fmt.Errorf("oidc: token audience %v not accepted", tok.Audience)
+	}
+	return o.identity(tok)
+}
+
+func (o *OIDC) identity(tok *oidc.IDToken) (*Identity, error) {
+	claims := map[string]interface{}{}
+	if err := tok.Claims(&claims); err != nil {
+		return nil, err
+	}
+	return MapClaims(o.mapping, tok.Subject, claims)
+}
+
+type loginState struct {
+	State    string `json:"s"`
+	Nonce    string `json:"n"`
+	Redirect string `json:"rd"`
+}
+
+func (o *OIDC) handleLogin(w http.ResponseWriter, r *http.Request) {
+	st := loginState{State: randomString(24), Nonce: randomString(24), Redirect: safeRedirect(r.URL.Query().Get("rd"))}
+	v, err := o.sessions.encode(useLoginState, st, 10*time.Minute)
+	if err != nil {
+		http.Error(w, "login error", http.StatusInternalServerError)
+		return
+	}
+	o.sessions.setCookie(w, r, stateCookie, v, 10*time.Minute)
+	http.Redirect(w, r, o.oauth2.AuthCodeURL(st.State, oidc.Nonce(st.Nonce)), http.StatusFound)
+}
+
+func (o *OIDC) handleCallback(w http.ResponseWriter, r *http.Request) {
+	c, err := r.Cookie(stateCookie)
+	if err != nil {
+		http.Error(w, "missing login state", http.StatusBadRequest)
+		return
+	}
+	o.sessions.setCookie(w, r, stateCookie, "", -1)
+	var st loginState
+	if err := o.sessions.decode(useLoginState, c.Value, &st); err != nil || st.State != r.URL.Query().Get("state") {
+		http.Error(w, "invalid login state", http.StatusBadRequest)
+		return
+	}
+	if e := r.URL.Query().Get("error"); e != "" {
+		o.logger.Warnw("oidc login rejected by provider", "error", e, "description", r.URL.Query().Get("error_description"))
+		http.Error(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+	tok, err := o.oauth2.Exchange(r.Context(), r.URL.Query().Get("code"))
+	if err != nil {
+		o.logger.Warnw("oidc code exchange failed", "error", err)
+		http.Error(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+	rawID, _ := tok.Extra("id_token").(string)
+	if rawID == "" {
+		http.Error(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+	idTok, err := o.verifier.Verify(r.Context(), rawID)
+	if err != nil || !intersects(idTok.Audience, []string{o.clientID}) || idTok.Nonce != st.Nonce {
+		o.logger.Warnw("oidc id token rejected", "error", err)
+		http.Error(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+	id, err := o.identity(idTok)
+	if err != nil {
+		o.logger.Warnw("oidc claims mapping failed", "error", err)
+		http.Error(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+	if err := o.sessions.Issue(w, r, id); err != nil {
+		http.Error(w, "login failed", http.StatusInternalServerError)
+		return
+	}
+	o.logger.Infow("oidc login", "user", id.Username, "groups", id.Groups)
+	http.Redirect(w, r, st.Redirect, http.StatusFound)
+}
+
+// MapClaims derives the identity from token claims using the configured claim names and prefixes.
+func MapClaims(m config.ClaimMapping, subject string, claims map[string]interface{}) (*Identity, error) {
+	userClaim := m.UsernameClaim
+	if userClaim == "" {
+		userClaim = "sub"
+	}
+	groupsClaim := m.GroupsClaim
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+	user, _ := claims[userClaim].(string)
+	if user == "" {
+		return nil, fmt.Errorf("claim %q missing", userClaim)
+	}
+	groups := []string{}
+	switch g := claims[groupsClaim].(type) {
+	case string:
+		groups = append(groups, g)
+	case []interface{}:
+		for _, v := range g {
+			if s, ok := v.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+	}
+	return MapIdentity(m, subject, user, groups), nil
+}
+
+// MapIdentity applies username/group prefixes so every authenticator yields comparable identities.
+func MapIdentity(m config.ClaimMapping, subject, user string, groups []string) *Identity {
+	id := &Identity{Subject: subject, Username: m.UsernamePrefix + user, Groups: make([]string, 0, len(groups))}
+	for _, g := range groups {
+		id.Groups = append(id.Groups, m.GroupsPrefix+g)
+	}
+	return id
+}
+
+func intersects(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// safeRedirect only allows local absolute paths to avoid open redirects.
+func safeRedirect(rd string) string {
+	if !strings.HasPrefix(rd, "/") || strings.HasPrefix(rd, "//") || strings.HasPrefix(rd, "/\\") {
+		return "/"
+	}
+	return rd
+}
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
+)
+
+const testIssuer = "https://issuer.example"
+
+// jwksServer serves the public half of key as a JWKS, standing in for the issuer.
+func jwksServer(t *testing.T, key *rsa.PrivateKey) string {
+	t.Helper()
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwks := map[string]interface{}{"keys": []map[string]string{{
+		"kty": "RSA", "kid": "k1", "alg": "RS256", "use": "sig",
+		"n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(jwks)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// signJWT returns an RS256 JWT with claims signed by key.
+func signJWT(t *testing.T, key *rsa.PrivateKey, claims map[string]interface{}) string {
+	t.Helper()
+	enc := func(v interface{}) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	unsigned := enc(map[string]string{"alg": "RS256", "kid": "k1", "typ": "JWT"}) + "." + enc(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestOIDCAuthenticateToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := config.OIDCSpec{IssuerURL: testIssuer, JWKSURL: jwksServer(t, key), ClientID: "pvc-viewer", Audiences: []string{"pvc-viewer", "pvc-viewer-cli"}}
+	o, err := NewOIDC(context.Background(), spec, "", nil, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := func(mod func(c map[string]interface{})) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss": testIssuer, "sub": "u1", "aud": []string{"pvc-viewer-cli"},
+			"exp": time.Now().Add(time.Hour).Unix(), "iat": time.Now().Unix(), "groups": []string{"team-a"},
+		}
+		if mod != nil {
+			mod(c)
+		}
+		return c
+	}
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{name: "good", token: signJWT(t, key, claims(nil))},
+		{name: "wrong audience", token: signJWT(t, key, claims(func(c map[string]interface{}) { c["aud"] = "other-client" })), wantErr: "audience"},
+		{name: "no audience", token: signJWT(t, key, claims(func(c map[string]interface{}) { delete(c, "aud") })), wantErr: "audience"},
+		{name: "expired", token: signJWT(t, key, claims(func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() })), wantErr: "expired"},
+		{name: "wrong issuer", token: signJWT(t, key, claims(func(c map[string]interface{}) { c["iss"] = "https://evil.example" })), wantErr: "issuer"},
+		{name: "bad signature", token: signJWT(t, other, claims(nil)), wantErr: "signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := o.AuthenticateToken(context.Background(), tt.token)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if id.Username != "u1" || len(id.Groups) != 1 || id.Groups[0] != "team-a" {
+					t.Errorf("identity %+v", id)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewOIDCRequiresAudience(t *testing.T) {
+	_, err := NewOIDC(context.Background(), config.OIDCSpec{IssuerURL: testIssuer, JWKSURL: "http://127.0.0.1:1/keys"}, "", nil, zap.NewNop().Sugar())
+	if err == nil {
+		t.Fatal("OIDC without clientID and audiences accepted")
+	}
+	o, err := NewOIDC(context.Background(), config.OIDCSpec{IssuerURL: testIssuer, JWKSURL: "http://127.0.0.1:1/keys", ClientID: "pvc-viewer"}, "", nil, zap.NewNop().Sugar())
+	if err != nil || len(o.audiences) != 1 || o.audiences[0] != "pvc-viewer" {
+		t.Fatalf("clientID not the default audience: %v %v", o, err)
+	}
+}
@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/matcher"
+)
+
+// Verb is an action on PVC contents checked by an Authorizer.
+type Verb string
+
+const (
+	VerbList     Verb = "list"
+	VerbDownload Verb = "download"
+	VerbUpload   Verb = "upload"
+	VerbDelete   Verb = "delete"
+	VerbEmpty    Verb = "empty"
+	// VerbAdmin guards cluster-wide operations (e.g. manual GC); ns/pvc are ignored.
+	VerbAdmin Verb = "admin"
+)
+
+// Authorizer decides whether id may perform verb on ns/pvc. id is nil for anonymous callers.
+// An empty pvc asks for every PVC in ns.
+type Authorizer interface {
+	Authorize(ctx context.Context, id *Identity, verb Verb, ns, pvc string) (bool, error)
+}
+
+// Rule is a compiled config.RBACRule.
+type Rule struct {
+	Users      matcher.Matcher
+	Groups     matcher.Matcher
+	Namespaces matcher.Matcher
+	PVCs       matcher.Matcher
+	Verbs      map[Verb]struct{} // nil => all verbs
+
+	anyone        bool
+	allNamespaces bool
+	allPVCs       bool
+}
+
+// Simple RBAC based on allowlists of namespace/PVC glob patterns
+type RBAC struct{ Rules []Rule }
+
+func NewRBAC(rules []config.RBACRule) *RBAC {
+	out := &RBAC{Rules: make([]Rule, 0, len(rules))}
+	for _, r := range rules {
+		rule := Rule{
+			Users:      matcher.New(r.Users, nil),
+			Groups:     matcher.New(r.Groups, nil),
+			Namespaces: matcher.New(r.Namespaces, nil),
+			PVCs:       matcher.New(r.PVCs, nil),
+			anyone:     len(r.Users) == 0 && len(r.Groups) == 0,
+		}
+		for _, ns := range r.Namespaces {
+			if ns == "*" || ns == "**" {
+				rule.allNamespaces = true
+			}
+		}
+		for _, p := range r.PVCs {
+			if p == "*" || p == "**" {
+				rule.allPVCs = true
+			}
+		}
+		if len(r.Verbs) > 0 {
+			rule.Verbs = map[Verb]struct{}{}
+			for _, v := range r.Verbs {
+				if v == "*" {
+					rule.Verbs = nil
+					break
+				}
+				rule.Verbs[Verb(v)] = struct{}{}
+			}
+		}
+		out.Rules = append(out.Rules, rule)
+	}
+	return out
+}
+
+// Allowed reports whether any rule grants verb on ns/pvc to id; an empty pvc needs a rule for all
+// PVCs of ns. Without rules everything is allowed.
+func (r *RBAC) Allowed(id *Identity, verb Verb, ns, pvc string) bool {
+	if len(r.Rules) == 0 {
+		return true
+	}
+	for _, rule := range r.Rules {
+		if !rule.subjectMatches(id) {
+			continue
+		}
+		if verb == VerbAdmin {
+			// cluster-wide: explicit "admin" verb, or all verbs on all namespaces
+			if _, ok := rule.Verbs[VerbAdmin]; ok || (rule.Verbs == nil && rule.allNamespaces) {
+				return true
+			}
+			continue
+		}
+		if rule.Verbs != nil {
+			if _, ok := rule.Verbs[verb]; !ok {
+				continue
+			}
+		}
+		if rule.Namespaces.Match(ns) && (rule.allPVCs || (pvc != "" && rule.PVCs.Match(pvc))) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RBAC) Authorize(_ context.Context, id *Identity, verb Verb, ns, pvc string) (bool, error) {
+	return r.Allowed(id, verb, ns, pvc), nil
+}
+
+func (rule Rule) subjectMatches(id *Identity) bool {
+	if rule.anyone {
+		return true
+	}
+	if id == nil {
+		return false
+	}
+	if rule.Users.Match(id.Username) {
+		return true
+	}
+	for _, g := range id.Groups {
+		if rule.Groups.Match(g) {
+			return true
+		}
+	}
+	return false
+}
+
+// Require wraps next with an authorization check for verb on the ns/pvc query parameters.
+func Require(authz func() Authorizer, verb Verb, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, _ := FromContext(r.Context())
+		ok, err := authz().Authorize(r.Context(), id, verb, r.URL.Query().Get("ns"), r.URL.Query().Get("pvc"))
+		if err != nil {
+			http.Error(w, "authorization error", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
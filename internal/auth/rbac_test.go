@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
+)
+
+func TestRBACAllowed(t *testing.T) {
+	// the rule the Helm chart renders from rbac.allowedNamespaces/allowedVerbs
+	chart := func(ns ...string) config.RBACRule {
+		return config.RBACRule{Namespaces: ns, PVCs: []string{"*"}, Verbs: []string{"list", "download", "upload", "delete", "empty"}}
+	}
+	alice := &Identity{Username: "alice", Groups: []string{"team-a"}}
+	tests := []struct {
+		name    string
+		rules   []config.RBACRule
+		id      *Identity
+		verb    Verb
+		ns, pvc string
+		want    bool
+	}{
+		{name: "no rules", verb: VerbDelete, ns: "a", pvc: "p", want: true},
+		{name: "chart without namespaces denies", rules: []config.RBACRule{chart()}, verb: VerbList, ns: "a", pvc: "p"},
+		{name: "chart namespaces", rules: []config.RBACRule{chart("team-*")}, verb: VerbUpload, ns: "team-a", pvc: "p", want: true},
+		{name: "chart other namespace", rules: []config.RBACRule{chart("team-*")}, verb: VerbUpload, ns: "kube-system", pvc: "p"},
+		{name: "chart never admin", rules: []config.RBACRule{chart("*")}, verb: VerbAdmin},
+		{name: "all verbs on all namespaces is admin", rules: []config.RBACRule{{Namespaces: []string{"*"}, PVCs: []string{"*"}}}, verb: VerbAdmin, want: true},
+		{name: "explicit admin", rules: []config.RBACRule{{Users: []string{"alice"}, Verbs: []string{"admin"}}}, id: alice, verb: VerbAdmin, want: true},
+		{name: "verb not granted", rules: []config.RBACRule{{Groups: []string{"team-a"}, Namespaces: []string{"a"}, PVCs: []string{"*"}, Verbs: []string{"list"}}}, id: alice, verb: VerbDownload, ns: "a", pvc: "p"},
+		{name: "group match", rules: []config.RBACRule{{Groups: []string{"team-*"}, Namespaces: []string{"a"}, PVCs: []string{"data-*"}, Verbs: []string{"*"}}}, id: alice, verb: VerbEmpty, ns: "a", pvc: "data-1", want: true},
+		{name: "pvc mismatch", rules: []config.RBACRule{{Groups: []string{"team-a"}, Namespaces: []string{"a"}, PVCs: []string{"data-*"}}}, id: alice, verb: VerbList, ns: "a", pvc: "logs"},
+		{name: "whole namespace", rules: []config.RBACRule{chart("a")}, verb: VerbList, ns: "a", want: true},
+		{name: "whole namespace needs all PVCs", rules: []config.RBACRule{{Namespaces: []string{"a"}, PVCs: []string{"data-*"}}}, verb: VerbList, ns: "a"},
+		{name: "whole namespace with a glob matching the empty name", rules: []config.RBACRule{{Namespaces: []string{"a"}, PVCs: []string{"{,data}"}}}, verb: VerbList, ns: "a"},
+		{name: "anonymous against subject rule", rules: []config.RBACRule{{Users: []string{"*"}, Namespaces: []string{"*"}, PVCs: []string{"*"}}}, verb: VerbList, ns: "a", pvc: "p"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewRBAC(tt.rules).Allowed(tt.id, tt.verb, tt.ns, tt.pvc); got != tt.want {
+				t.Errorf("Allowed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
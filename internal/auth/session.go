@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var errBadCookie = errors.New("invalid or expired cookie")
+
+// SessionManager issues HMAC-signed, stateless session cookies carrying the caller identity.
+// All replicas must share the same key for sessions to survive load balancing.
+type SessionManager struct {
+	CookieName string
+	TTL        time.Duration
+	key        []byte
+}
+
+// NewSessionManager creates a manager; an empty key is replaced by a random per-process key.
+func NewSessionManager(cookieName string, ttl time.Duration, key []byte) *SessionManager {
+	if cookieName == "" {
+		cookieName = "pvc_viewer_session"
+	}
+	if ttl <= 0 {
+		ttl = 8 * time.Hour
+	}
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		_, _ = rand.Read(key)
+	}
+	return &SessionManager{CookieName: cookieName, TTL: ttl, key: key}
+}
+
+// Cookie purposes signed into the envelope, so a value issued for one cookie is rejected as another.
+const (
+	useSession    = "session"
+	useLoginState = "oidc-state"
+)
+
+type envelope struct {
+	Use  string          `json:"u"`
+	Exp  int64           `json:"exp"`
+	Data json.RawMessage `json:"d"`
+}
+
+// encode signs v with its purpose and an expiry: base64(payload).base64(hmac)
+func (m *SessionManager) encode(use string, v interface{}, ttl time.Duration) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(envelope{Use: use, Exp: time.Now().Add(ttl).Unix(), Data: data})
+	if err != nil {
+		return "", err
+	}
+	p := base64.RawURLEncoding.EncodeToString(payload)
+	return p + "." + base64.RawURLEncoding.EncodeToString(m.sign(p)), nil
+}
+
+func (m *SessionManager) decode(use, s string, v interface{}) error {
+	p, sig, ok := strings.Cut(s, ".")
+	if !ok {
+		return errBadCookie
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, m.sign(p)) {
+		return errBadCookie
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(p)
+	if err != nil {
+		return errBadCookie
+	}
+	var env envelope
+	if err := json.Unmarshal(payload, &env); err != nil {
+		return errBadCookie
+	}
+	if env.Use != use || time.Now().Unix() > env.Exp {
+		return errBadCookie
+	}
+	return json.Unmarshal(env.Data, v)
+}
+
+func (m *SessionManager) sign(p string) []byte {
+	h := hmac.New(sha256.New, m.key)
+	_, _ = h.Write([]byte(p))
+	return h.Sum(nil)
+}
+
+// Issue sets the session cookie for id.
+func (m *SessionManager) Issue(w http.ResponseWriter, r *http.Request, id *Identity) error {
+	v, err := m.encode(useSession, id, m.TTL)
+	if err != nil {
+		return err
+	}
+	m.setCookie(w, r, m.CookieName, v, m.TTL)
+	return nil
+}
+
+// Read returns the identity from a valid session cookie; a session without a username is rejected.
+func (m *SessionManager) Read(r *http.Request) (*Identity, bool) {
+	c, err := r.Cookie(m.CookieName)
+	if err != nil {
+		return nil, false
+	}
+	var id Identity
+	if err := m.decode(useSession, c.Value, &id); err != nil || id.Username == "" {
+		return nil, false
+	}
+	return &id, true
+}
+
+func (m *SessionManager) Clear(w http.ResponseWriter, r *http.Request) {
+	m.setCookie(w, r, m.CookieName, "", -1)
+}
+
+func (m *SessionManager) setCookie(w http.ResponseWriter, r *http.Request, name, value string, ttl time.Duration) {
+	c := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	}
+	if ttl < 0 {
+		c.MaxAge = -1
+	} else {
+		c.MaxAge = int(ttl.Seconds())
+	}
+	http.SetCookie(w, c)
+}
+
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+func randomString(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// roundTrip sets the cookies of rr on a new request.
+func roundTrip(rr *httptest.ResponseRecorder, name string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == name {
+			req.AddCookie(c)
+		}
+	}
+	return req
+}
+
+func TestSessionRead(t *testing.T) {
+	m := NewSessionManager("", time.Hour, []byte("key"))
+	rr := httptest.NewRecorder()
+	if err := m.Issue(rr, httptest.NewRequest(http.MethodGet, "/", nil), &Identity{Username: "alice", Groups: []string{"team-a"}}); err != nil {
+		t.Fatal(err)
+	}
+	id, ok := m.Read(roundTrip(rr, m.CookieName))
+	if !ok || id.Username != "alice" || len(id.Groups) != 1 {
+		t.Fatalf("issued session not read back: %+v %t", id, ok)
+	}
+
+	other := NewSessionManager("", time.Hour, []byte("other key"))
+	if _, ok := other.Read(roundTrip(rr, m.CookieName)); ok {
+		t.Error("session signed with another key accepted")
+	}
+
+	rr = httptest.NewRecorder()
+	if err := m.Issue(rr, httptest.NewRequest(http.MethodGet, "/", nil), &Identity{Subject: "s"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Read(roundTrip(rr, m.CookieName)); ok {
+		t.Error("session without a username accepted")
+	}
+}
+
+// TestSessionRejectsLoginState replays the OIDC login state cookie, which anyone gets from
+// /auth/login without logging in, as the session cookie.
+func TestSessionRejectsLoginState(t *testing.T) {
+	m := NewSessionManager("", time.Hour, []byte("key"))
+	o := &OIDC{sessions: m, oauth2: &oauth2.Config{ClientID: "pvc-viewer", Endpoint: oauth2.Endpoint{AuthURL: testIssuer + "/auth"}}}
+	rr := httptest.NewRecorder()
+	o.handleLogin(rr, httptest.NewRequest(http.MethodGet, "/auth/login", nil))
+	var state *http.Cookie
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == stateCookie {
+			state = c
+		}
+	}
+	if state == nil || state.Value == "" {
+		t.Fatal("no login state cookie")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: m.CookieName, Value: state.Value})
+	if id, ok := m.Read(req); ok {
+		t.Fatalf("login state accepted as a session: %+v", id)
+	}
+
+	// and a session is no login state
+	rr = httptest.NewRecorder()
+	if err := m.Issue(rr, httptest.NewRequest(http.MethodGet, "/", nil), &Identity{Username: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	var st loginState
+	if err := m.decode(useLoginState, rr.Result().Cookies()[0].Value, &st); err == nil {
+		t.Error("session accepted as login state")
+	}
+}
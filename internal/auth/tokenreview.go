@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
+)
+
+// TokenReviewAuthenticator validates Kubernetes (projected ServiceAccount) tokens via the
+// TokenReview API. Tokens must be issued for one of Audiences.
+type TokenReviewAuthenticator struct {
+	Client    kubernetes.Interface
+	Audiences []string
+	Mapping   config.ClaimMapping // only prefixes are used
+	TTL       time.Duration       // cache for successful reviews
+
+	mu    sync.Mutex
+	cache map[[32]byte]cachedIdentity
+}
+
+type cachedIdentity struct {
+	id      *Identity
+	expires time.Time
+}
+
+func NewTokenReviewAuthenticator(client kubernetes.Interface, spec config.ServiceAccountAuthSpec) (*TokenReviewAuthenticator, error) {
+	if len(spec.Audiences) == 0 {
+		return nil, errors.New("tokenreview: at least one audience is required")
+	}
+	ttl := spec.CacheTTL
+	if ttl <= 0 {
+		ttl = 10 * time.Second
+	}
+	return &TokenReviewAuthenticator{
+		Client:    client,
+		Audiences: spec.Audiences,
+		Mapping:   config.ClaimMapping{UsernamePrefix: spec.UsernamePrefix, GroupsPrefix: spec.GroupsPrefix},
+		TTL:       ttl,
+		cache:     map[[32]byte]cachedIdentity{},
+	}, nil
+}
+
+func (t *TokenReviewAuthenticator) AuthenticateToken(ctx context.Context, token string) (*Identity, error) {
+	k := sha256.Sum256([]byte(token))
+	now := time.Now()
+	t.mu.Lock()
+	if c, ok := t.cache[k]; ok && now.Before(c.expires) {
+		t.mu.Unlock()
+		return c.id, nil
+	}
+	t.mu.Unlock()
+
+	tr := &authenticationv1.TokenReview{Spec: authenticationv1.TokenReviewSpec{Token: token, Audiences: t.Audiences}}
+	res, err := t.Client.AuthenticationV1().TokenReviews().Create(ctx, tr, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("tokenreview: %w", err)
+	}
+	if !res.Status.Authenticated {
+		if res.Status.Error != "" {
+			return nil, fmt.Errorf("tokenreview: %s", res.Status.Error)
+		}
+		return nil, ErrUnauthenticated
+	}
+	// an empty status.audiences means the authenticator is not audience-aware; reject to be safe
+	if !intersects(res.Status.Audiences, t.Audiences) {
+		return nil, fmt.Errorf("tokenreview: token audience %v not accepted", res.Status.Audiences)
+	}
+	u := res.Status.User
+	id := MapIdentity(t.Mapping, u.UID, u.Username, u.Groups)
+
+	t.mu.Lock()
+	for ck, c := range t.cache {
+		if now.After(c.expires) {
+			delete(t.cache, ck)
+		}
+	}
+	t.cache[k] = cachedIdentity{id: id, expires: now.Add(t.TTL)}
+	t.mu.Unlock()
+	return id, nil
+}
@@ -0,0 +1,166 @@
+package backend
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// AgentInstallSecretName holds the per-install token in the backend namespace. It never leaves
+// that namespace: agents get a token derived from it for their own namespace only.
+const AgentInstallSecretName = "pvc-viewer-agent-install"
+
+// AgentAuthSecretName holds the token of a namespace's agents (AgentTokenFor) in that namespace.
+const AgentAuthSecretName = "pvc-viewer-agent-auth"
+
+const agentTokenKey = "token"
+
+// EnsureAgentToken returns the per-install token, creating its Secret on first start.
+// All backend replicas share it, so any replica can talk to any agent.
+func EnsureAgentToken(ctx context.Context, client kubernetes.Interface, ns string) (string, error) {
+	if s, err := client.CoreV1().Secrets(ns).Get(ctx, AgentInstallSecretName, metav1.GetOptions{}); err == nil {
+		if tok := string(s.Data[agentTokenKey]); tok != "" {
+			return tok, nil
+		}
+	} else if !apierrors.IsNotFound(err) {
+		return "", err
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	tok := hex.EncodeToString(b)
+	sec := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: AgentInstallSecretName, Namespace: ns, Labels: map[string]string{"app": "pvc-viewer-backend"}},
+		Data:       map[string][]byte{agentTokenKey: []byte(tok)},
+	}
+	if _, err := client.CoreV1().Secrets(ns).Create(ctx, sec, metav1.CreateOptions{}); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return "", err
+		}
+		// another replica won the race
+		s, err := client.CoreV1().Secrets(ns).Get(ctx, AgentInstallSecretName, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		return string(s.Data[agentTokenKey]), nil
+	}
+	return tok, nil
+}
+
+// AgentTokenFor derives the token of the agents in ns from the per-install token, so a token
+// read from one namespace does not open agents in another. Empty without a per-install token.
+func AgentTokenFor(token, ns string) string {
+	if token == "" {
+		return ""
+	}
+	m := hmac.New(sha256.New, []byte(token))
+	m.Write([]byte(ns))
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// agentSecrets remembers namespaces whose token Secret is up to date, so reconciles do not
+// rewrite Secrets.
+type agentSecrets struct {
+	mu    sync.Mutex
+	known map[string]string // ns -> token hash written
+}
+
+// ensureAgentSecret writes the token of ns into ns (once per backend process and token). An
+// existing Secret of that name is only updated when it is ours: labelled as agent Secret and,
+// with an owner anchor, owned by it.
+func (r *Reconciler) ensureAgentSecret(ctx context.Context, ns string) error {
+	if r.AgentToken == "" {
+		return nil
+	}
+	h := r.agentTokenHash()
+	r.secrets.mu.Lock()
+	defer r.secrets.mu.Unlock()
+	if r.secrets.known[ns] == h {
+		return nil
+	}
+	sec := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: AgentAuthSecretName, Namespace: ns, Labels: map[string]string{"app": "pvc-viewer-agent"}, OwnerReferences: r.ownerRefs()},
+		Data:       map[string][]byte{agentTokenKey: []byte(AgentTokenFor(r.AgentToken, ns))},
+	}
+	if _, err := r.Client.CoreV1().Secrets(ns).Create(ctx, sec, metav1.CreateOptions{}); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+		existing, err := r.Client.CoreV1().Secrets(ns).Get(ctx, AgentAuthSecretName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if !r.ownsAgentSecret(existing) {
+			return fmt.Errorf("secret %s/%s exists and is not managed by pvc-viewer", ns, AgentAuthSecretName)
+		}
+		existing.Data = sec.Data
+		if _, err := r.Client.CoreV1().Secrets(ns).Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+	}
+	if r.secrets.known == nil {
+		r.secrets.known = map[string]string{}
+	}
+	r.secrets.known[ns] = h
+	return nil
+}
+
+// gcAgentSecret removes the token Secret from ns once no agents of either kind remain there.
+func (r *Reconciler) gcAgentSecret(ctx context.Context, ns string) {
+	for _, sel := range []string{"app=pvc-viewer-agent", "app=pvc-viewer-agent-ns"} {
+		pods, err := r.Client.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{LabelSelector: sel})
+		if err != nil {
+			return
+		}
+		for _, p := range pods.Items {
+			if p.DeletionTimestamp == nil {
+				return
+			}
+		}
+	}
+	if sec, err := r.Client.CoreV1().Secrets(ns).Get(ctx, AgentAuthSecretName, metav1.GetOptions{}); err == nil && r.ownsAgentSecret(sec) {
+		_ = r.Client.CoreV1().Secrets(ns).Delete(ctx, AgentAuthSecretName, metav1.DeleteOptions{Preconditions: &metav1.Preconditions{UID: &sec.UID}})
+	}
+	r.secrets.mu.Lock()
+	delete(r.secrets.known, ns)
+	r.secrets.mu.Unlock()
+}
+
+// ownsAgentSecret reports whether sec was written by ensureAgentSecret.
+func (r *Reconciler) ownsAgentSecret(sec *corev1.Secret) bool {
+	return sec.Labels["app"] == "pvc-viewer-agent" && hasOwner(sec.OwnerReferences, r.Owner)
+}
+
+// agentTokenHash is part of agent spec hashes so that token rotation rolls agents.
+func (r *Reconciler) agentTokenHash() string {
+	if r.AgentToken == "" {
+		return ""
+	}
+	h := sha1.Sum([]byte(r.AgentToken))
+	return hex.EncodeToString(h[:4])
+}
+
+// agentEnv returns the agent container environment.
+func (r *Reconciler) agentEnv(readOnly bool) []corev1.EnvVar {
+	env := []corev1.EnvVar{{Name: "PVC_VIEWER_DATA_ROOT", Value: "/data"}, {Name: "PVC_VIEWER_READ_ONLY", Value: boolString(readOnly)}}
+	if r.AgentToken != "" {
+		env = append(env, corev1.EnvVar{Name: "PVC_VIEWER_AGENT_TOKEN", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: AgentAuthSecretName},
+			Key:                  agentTokenKey,
+		}}})
+	} else {
+		env = append(env, corev1.EnvVar{Name: "PVC_VIEWER_AGENT_INSECURE", Value: "true"})
+	}
+	return env
+}
@@ -0,0 +1,90 @@
+package backend
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAgentTokenFor(t *testing.T) {
+	if AgentTokenFor("", "ns") != "" {
+		t.Error("token derived without a per-install token")
+	}
+	a, b := AgentTokenFor("install", "a"), AgentTokenFor("install", "b")
+	if a == "" || a == b || a == "install" {
+		t.Errorf("tokens not per namespace: %q %q", a, b)
+	}
+	if AgentTokenFor("install", "a") != a || AgentTokenFor("other", "a") == a {
+		t.Error("token not derived from the install token")
+	}
+}
+
+func TestEnsureAgentSecret(t *testing.T) {
+	owner := &metav1.OwnerReference{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole", Name: "pvc-viewer-anchor", UID: "anchor-uid"}
+	tests := []struct {
+		name     string
+		existing *corev1.Secret
+		wantErr  bool
+	}{
+		{name: "created"},
+		{name: "ours updated", existing: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "pvc-viewer-agent"}, OwnerReferences: []metav1.OwnerReference{*owner}}, Data: map[string][]byte{agentTokenKey: []byte("old")}}},
+		{name: "unlabelled refused", existing: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{OwnerReferences: []metav1.OwnerReference{*owner}}}, wantErr: true},
+		{name: "foreign owner refused", existing: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "pvc-viewer-agent"}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			if tt.existing != nil {
+				tt.existing.Name, tt.existing.Namespace = AgentAuthSecretName, "team"
+				if tt.existing.Data == nil {
+					tt.existing.Data = map[string][]byte{agentTokenKey: []byte("foreign")}
+				}
+				_, _ = client.CoreV1().Secrets("team").Create(context.Background(), tt.existing, metav1.CreateOptions{})
+			}
+			r := &Reconciler{Client: client, AgentToken: "install", Owner: owner}
+			err := r.ensureAgentSecret(context.Background(), "team")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			sec, _ := client.CoreV1().Secrets("team").Get(context.Background(), AgentAuthSecretName, metav1.GetOptions{})
+			got := string(sec.Data[agentTokenKey])
+			switch {
+			case tt.wantErr && got != "foreign":
+				t.Errorf("foreign Secret overwritten: %q", got)
+			case !tt.wantErr && got != AgentTokenFor("install", "team"):
+				t.Errorf("token %q, want the namespace token", got)
+			}
+
+			// gc only removes our Secret
+			r.gcAgentSecret(context.Background(), "team")
+			_, err = client.CoreV1().Secrets("team").Get(context.Background(), AgentAuthSecretName, metav1.GetOptions{})
+			if (err == nil) != tt.wantErr {
+				t.Errorf("after gc: err = %v", err)
+			}
+		})
+	}
+}
+
+func TestEnsureAgentTokenKeepsInstallSecret(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	tok, err := EnsureAgentToken(context.Background(), client, "pvc-viewer")
+	if err != nil || tok == "" {
+		t.Fatalf("token %q err %v", tok, err)
+	}
+	again, _ := EnsureAgentToken(context.Background(), client, "pvc-viewer")
+	if again != tok {
+		t.Error("token changed between starts")
+	}
+	// agents in the release namespace get their own Secret, not the install token
+	r := &Reconciler{Client: client, AgentToken: tok}
+	if err := r.ensureAgentSecret(context.Background(), "pvc-viewer"); err != nil {
+		t.Fatal(err)
+	}
+	src, _ := client.CoreV1().Secrets("pvc-viewer").Get(context.Background(), AgentInstallSecretName, metav1.GetOptions{})
+	if string(src.Data[agentTokenKey]) != tok {
+		t.Error("install Secret overwritten")
+	}
+}
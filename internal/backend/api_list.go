@@ -4,23 +4,26 @@ import (
 	"encoding/json"
 	"net/http"
 	"sort"
+	"sync"
 
-	"k8s.io/client-go/kubernetes"
-
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/auth"
 	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
 )
 
 // RegisterReadAPIs wires list endpoints into the router.
 // It filters namespaces/PVCs using the same matching logic as reconciliation,
-// so the UI only shows items that the data plane is actually serving.
+// so the UI only shows items that the data plane is actually serving,
+// and drops items the caller is not allowed to list.
 func RegisterReadAPIs(mux interface {
 	Get(string, http.HandlerFunc)
-}, client kubernetes.Interface, cfgProvider func() *config.Config) {
+}, d *Discovery, cfgProvider func() *config.Config, authz func() auth.Authorizer) {
 	mux.Get("/namespaces", func(w http.ResponseWriter, r *http.Request) {
 		cfg := cfgProvider()
 		// Build eligible targets and return unique namespaces
-		d := &Discovery{Client: client}
 		targets, err := d.BuildTargets(r.Context(), cfg)
+		if err == nil {
+			targets, err = visibleTargets(r, authz(), targets)
+		}
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -44,9 +47,11 @@ func RegisterReadAPIs(mux interface {
 			return
 		}
 		cfg := cfgProvider()
-		d := &Discovery{Client: client}
 		// Faster: only scan selected namespace
 		targets, err := d.BuildTargetsForNamespace(r.Context(), cfg, ns)
+		if err == nil {
+			targets, err = visibleTargets(r, authz(), targets)
+		}
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -60,3 +65,77 @@ func RegisterReadAPIs(mux interface {
 		_ = json.NewEncoder(w).Encode(names)
 	})
 }
+
+// maxAuthzChecks bounds the authorization checks (SubjectAccessReviews in kubernetes mode) one
+// list request runs at a time.
+const maxAuthzChecks = 8
+
+// visibleTargets keeps targets the caller may list. Each namespace is checked once for all its
+// PVCs; only PVCs of namespaces where that is denied are checked one by one.
+func visibleTargets(r *http.Request, authz auth.Authorizer, targets []Target) ([]Target, error) {
+	id, _ := auth.FromContext(r.Context())
+	list := func(ns, pvc string) (bool, error) {
+		return authz.Authorize(r.Context(), id, auth.VerbList, ns, pvc)
+	}
+	var namespaces []string
+	seen := map[string]bool{}
+	for _, t := range targets {
+		if !seen[t.Namespace] {
+			seen[t.Namespace] = true
+			namespaces = append(namespaces, t.Namespace)
+		}
+	}
+	nsAllowed, err := authorizeEach(len(namespaces), func(i int) (bool, error) { return list(namespaces[i], "") })
+	if err != nil {
+		return nil, err
+	}
+	whole := map[string]bool{}
+	for i, ns := range namespaces {
+		whole[ns] = nsAllowed[i]
+	}
+	var rest []Target
+	for _, t := range targets {
+		if !whole[t.Namespace] {
+			rest = append(rest, t)
+		}
+	}
+	pvcAllowed, err := authorizeEach(len(rest), func(i int) (bool, error) { return list(rest[i].Namespace, rest[i].PVCName) })
+	if err != nil {
+		return nil, err
+	}
+	allowed := map[string]bool{}
+	for i, t := range rest {
+		allowed[key(t)] = pvcAllowed[i]
+	}
+	out := make([]Target, 0, len(targets))
+	for _, t := range targets {
+		if whole[t.Namespace] || allowed[key(t)] {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+// authorizeEach runs check for 0..n-1, at most maxAuthzChecks at a time, and returns the results
+// in order or the first error.
+func authorizeEach(n int, check func(i int) (bool, error)) ([]bool, error) {
+	out := make([]bool, n)
+	errs := make([]error, n)
+	sem := make(chan struct{}, maxAuthzChecks)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+			out[i], errs[i] = check(i)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,75 @@
+package backend
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/auth"
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/kube"
+)
+
+func TestVisibleTargets(t *testing.T) {
+	targets := []Target{
+		{Namespace: "a", PVCName: "p1"}, {Namespace: "a", PVCName: "p2"}, {Namespace: "a", PVCName: "p3"},
+		{Namespace: "b", PVCName: "x"}, {Namespace: "b", PVCName: "y"},
+		{Namespace: "c", PVCName: "z"},
+	}
+	// alice may get every PVC in a, and only x in b
+	decide := func(a *authorizationv1.ResourceAttributes) bool {
+		return a.Namespace == "a" || (a.Namespace == "b" && a.Name == "x")
+	}
+	var mu sync.Mutex
+	var reviews []string
+	var failOn string
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "subjectaccessreviews", func(act k8stesting.Action) (bool, runtime.Object, error) {
+		sar := act.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		a := sar.Spec.ResourceAttributes
+		mu.Lock()
+		reviews = append(reviews, a.Namespace+"/"+a.Name)
+		mu.Unlock()
+		if a.Namespace+"/"+a.Name == failOn {
+			return true, nil, errors.New("apiserver down")
+		}
+		sar.Status.Allowed = decide(a)
+		return true, sar, nil
+	})
+	authz := &auth.KubeAuthorizer{Reviewer: kube.NewAccessReviewer(client, time.Minute)}
+	req := httptest.NewRequest(http.MethodGet, "/namespaces", nil)
+	req = req.WithContext(auth.WithIdentity(req.Context(), &auth.Identity{Username: "alice"}))
+
+	got, err := visibleTargets(req, authz, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Target{targets[0], targets[1], targets[2], targets[3]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("visible %v, want %v", got, want)
+	}
+	// one review per namespace, per PVC only where the namespace was denied
+	want := map[string]bool{"a/": true, "b/": true, "c/": true, "b/x": true, "b/y": true, "c/z": true}
+	if len(reviews) != len(want) {
+		t.Errorf("reviews %v, want %d", reviews, len(want))
+	}
+	for _, r := range reviews {
+		if !want[r] {
+			t.Errorf("unexpected review %s", r)
+		}
+	}
+
+	// a failed review fails the list closed
+	authz = &auth.KubeAuthorizer{Reviewer: kube.NewAccessReviewer(client, time.Minute)}
+	failOn = "b/y"
+	if got, err := visibleTargets(req, authz, targets); err == nil {
+		t.Errorf("review error ignored, got %v", got)
+	}
+}
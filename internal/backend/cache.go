@@ -0,0 +1,67 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// agentPodSelector matches per-PVC and per-namespace agent Pods and their Services.
+const agentPodSelector = "app in (pvc-viewer-agent,pvc-viewer-agent-ns)"
+
+// Cache holds shared informers for Namespaces, PVCs, PVs and agent Pods and Services, so
+// discovery, the list APIs and reconciliation read from memory instead of the API server.
+type Cache struct {
+	Namespaces    corelisters.NamespaceLister
+	PVCs          corelisters.PersistentVolumeClaimLister
+	PVs           corelisters.PersistentVolumeLister
+	AgentPods     corelisters.PodLister
+	AgentServices corelisters.ServiceLister
+
+	factory    informers.SharedInformerFactory
+	podFactory informers.SharedInformerFactory
+
+	NamespaceInformer cache.SharedIndexInformer
+	PVCInformer       cache.SharedIndexInformer
+	PodInformer       cache.SharedIndexInformer
+}
+
+func NewCache(client kubernetes.Interface, resync time.Duration) *Cache {
+	f := informers.NewSharedInformerFactory(client, resync)
+	pf := informers.NewSharedInformerFactoryWithOptions(client, resync, informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+		o.LabelSelector = agentPodSelector
+	}))
+	c := &Cache{factory: f, podFactory: pf}
+	c.NamespaceInformer = f.Core().V1().Namespaces().Informer()
+	c.PVCInformer = f.Core().V1().PersistentVolumeClaims().Informer()
+	c.PodInformer = pf.Core().V1().Pods().Informer()
+	c.Namespaces = f.Core().V1().Namespaces().Lister()
+	c.PVCs = f.Core().V1().PersistentVolumeClaims().Lister()
+	c.PVs = f.Core().V1().PersistentVolumes().Lister()
+	c.AgentPods = pf.Core().V1().Pods().Lister()
+	c.AgentServices = pf.Core().V1().Services().Lister()
+	return c
+}
+
+// Start runs the informers and blocks until their caches are synced.
+func (c *Cache) Start(ctx context.Context) error {
+	c.factory.Start(ctx.Done())
+	c.podFactory.Start(ctx.Done())
+	for t, ok := range c.factory.WaitForCacheSync(ctx.Done()) {
+		if !ok {
+			return fmt.Errorf("cache sync failed for %v", t)
+		}
+	}
+	for t, ok := range c.podFactory.WaitForCacheSync(ctx.Done()) {
+		if !ok {
+			return fmt.Errorf("cache sync failed for %v", t)
+		}
+	}
+	return nil
+}
@@ -2,55 +2,244 @@ package backend
 
 import (
 	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
 
 	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
 )
 
-// Controller wires config hot-reload to reconciliation loop.
+// Work queue keys. keyAll reconciles everything (config changes, leadership, periodic resync);
+// "ns:<ns>" and "pvc:<ns>/<name>" reconcile a single namespace or PVC after an informer event.
+const (
+	keyAll       = "all"
+	keyNSPrefix  = "ns:"
+	keyPVCPrefix = "pvc:"
+)
+
+// Controller wires config hot-reload and informer events to the reconciliation loop.
 type Controller struct {
 	Recon  *Reconciler
 	Disc   *Discovery
+	Mounts *MountDataPlane
 	Logger *zap.SugaredLogger
+
+	electionEnabled atomic.Bool
+	leader          atomic.Bool
+
+	queueOnce sync.Once
+	queue     workqueue.TypedRateLimitingInterface[string]
 }
 
+func (c *Controller) workqueue() workqueue.TypedRateLimitingInterface[string] {
+	c.queueOnce.Do(func() {
+		c.queue = workqueue.NewTypedRateLimitingQueueWithConfig(workqueue.DefaultTypedControllerRateLimiter[string](), workqueue.TypedRateLimitingQueueConfig[string]{Name: "pvc-viewer"})
+	})
+	return c.queue
+}
+
+// configDebounce delays the full resync after a config change so a burst of reloads queues one.
+const configDebounce = 200 * time.Millisecond
+
+// OnConfigChange applies cfg's backend mounts right away and queues a full resync.
 func (c *Controller) OnConfigChange(ctx context.Context, cfg *config.Config) {
-	// Debounce rapid changes
+	if cfg.Mode.DataPlane == ModeMountInBackend && c.Mounts != nil {
+		// every replica serves mounted PVCs
+		c.Mounts.Apply(cfg)
+	}
+	c.workqueue().AddAfter(keyAll, configDebounce)
+}
+
+// Start registers informer event handlers, waits for the cache to sync and runs workers until
+// ctx is done. Events are queued on every replica but only the leader acts on them.
+func (c *Controller) Start(ctx context.Context, cfgProvider func() *config.Config, workers int) error {
+	q := c.workqueue()
 	go func() {
-		time.Sleep(200 * time.Millisecond)
-		if cfg.Mode.DataPlane == "agent-per-pvc" {
+		<-ctx.Done()
+		q.ShutDown()
+	}()
+	if ch := c.Disc.Cache; ch != nil {
+		pvcKey := func(ns, name string) string {
+			if cfgProvider().Mode.DataPlane == "agent-per-namespace" {
+				return keyNSPrefix + ns
+			}
+			return keyPVCPrefix + ns + "/" + name
+		}
+		_, _ = ch.PVCInformer.AddEventHandler(eventHandler(func(obj interface{}) {
+			if p, ok := obj.(*corev1.PersistentVolumeClaim); ok {
+				q.Add(pvcKey(p.Namespace, p.Name))
+			}
+		}))
+		_, _ = ch.NamespaceInformer.AddEventHandler(eventHandler(func(obj interface{}) {
+			if n, ok := obj.(*corev1.Namespace); ok {
+				q.Add(keyNSPrefix + n.Name)
+			}
+		}))
+		// a deleted agent Pod is recreated right away instead of on the next resync
+		_, _ = ch.PodInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{DeleteFunc: func(obj interface{}) {
+			if t, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = t.Obj
+			}
+			p, ok := obj.(*corev1.Pod)
+			if !ok {
+				return
+			}
+			if pvc := p.Labels["pvcviewer.k8s.io/pvc"]; pvc != "" {
+				q.Add(pvcKey(p.Namespace, pvc))
+			} else if p.Labels["app"] == "pvc-viewer-agent-ns" {
+				q.Add(keyNSPrefix + p.Namespace)
+			}
+		}})
+		if err := ch.Start(ctx); err != nil {
+			return err
+		}
+		c.Logger.Infow("informer caches synced")
+	}
+	if p := c.Disc.Policies; p != nil {
+		_, _ = p.Informer.AddEventHandler(eventHandler(func(obj interface{}) {
+			if o, ok := obj.(interface{ GetNamespace() string }); ok {
+				q.Add(keyNSPrefix + o.GetNamespace())
+			}
+		}))
+		if err := p.Start(ctx); err != nil {
+			return err
+		}
+		c.Logger.Infow("PVCViewerPolicy informer synced")
+	}
+	if workers <= 0 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for c.processNext(ctx, cfgProvider) {
+			}
+		}()
+	}
+	q.Add(keyAll)
+	return nil
+}
+
+// eventHandler calls enqueue on add, delete and real updates (not resyncs with an unchanged object).
+func eventHandler(enqueue func(obj interface{})) cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: enqueue,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			o, ok1 := oldObj.(interface{ GetResourceVersion() string })
+			n, ok2 := newObj.(interface{ GetResourceVersion() string })
+			if ok1 && ok2 && o.GetResourceVersion() == n.GetResourceVersion() {
+				return
+			}
+			enqueue(newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if t, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = t.Obj
+			}
+			enqueue(obj)
+		},
+	}
+}
+
+func (c *Controller) processNext(ctx context.Context, cfgProvider func() *config.Config) bool {
+	q := c.workqueue()
+	k, shutdown := q.Get()
+	if shutdown {
+		return false
+	}
+	defer q.Done(k)
+	if !c.IsLeader() {
+		// the new leader starts with a full reconcile
+		q.Forget(k)
+		return true
+	}
+	cfg := cfgProvider()
+	if err := c.sync(ctx, cfg, k); err != nil {
+		c.Logger.Warnw("reconcile failed; retrying", "key", k, "retries", q.NumRequeues(k), "error", err)
+		q.AddRateLimited(k)
+		return true
+	}
+	q.Forget(k)
+	c.syncPolicyStatus(ctx, cfg, k)
+	return true
+}
+
+// sync reconciles one work queue key under the current data plane mode.
+func (c *Controller) sync(ctx context.Context, cfg *config.Config, k string) error {
+	mode := cfg.Mode.DataPlane
+	if k == keyAll {
+		switch mode {
+		case "agent-per-pvc":
 			targets, err := c.Disc.BuildTargets(ctx, cfg)
 			if err != nil {
-				c.Logger.Warnw("build targets failed", "error", err)
-				return
+				return err
 			}
-			if err := c.Recon.Reconcile(ctx, targets); err != nil {
-				c.Logger.Warnw("reconcile failed", "error", err)
+			return c.Recon.Reconcile(ctx, cfg, targets)
+		case "agent-per-namespace":
+			return c.reconcilePerNamespace(ctx, cfg)
+		case ModeMountInBackend:
+			c.reconcileMountInBackend(ctx)
+		default:
+			c.Logger.Warnw("unknown data plane mode; nothing to reconcile", "mode", mode)
+		}
+		return nil
+	}
+	if ns, ok := strings.CutPrefix(k, keyNSPrefix); ok {
+		targets, err := c.Disc.BuildTargetsForNamespace(ctx, cfg, ns)
+		if err != nil {
+			return err
+		}
+		switch mode {
+		case "agent-per-pvc":
+			return c.Recon.ReconcileNamespace(ctx, cfg, ns, targets)
+		case "agent-per-namespace":
+			if len(targets) == 0 {
+				return c.Recon.RemoveNamespaceAgents(ctx, ns)
 			}
-			return
+			return c.Recon.EnsureNamespaceAgent(ctx, cfg, ns, targets)
 		}
-		if cfg.Mode.DataPlane == "agent-per-namespace" {
-			c.reconcilePerNamespace(ctx, cfg)
-			return
+		return nil
+	}
+	if nsName, ok := strings.CutPrefix(k, keyPVCPrefix); ok && mode == "agent-per-pvc" {
+		ns, name, _ := strings.Cut(nsName, "/")
+		targets, err := c.Disc.BuildTargetsForNamespace(ctx, cfg, ns)
+		if err != nil {
+			return err
 		}
-	}()
+		for _, t := range targets {
+			if t.PVCName == name {
+				return c.Recon.EnsureTarget(ctx, cfg, t)
+			}
+		}
+		c.Recon.RemoveAgent(ctx, ns, name)
+	}
+	return nil
+}
+
+// reconcileMountInBackend removes agents left over from other modes.
+func (c *Controller) reconcileMountInBackend(ctx context.Context) {
+	if err := c.Recon.GCPerPVCAll(ctx); err != nil {
+		c.Logger.Warnw("gc per-pvc agents failed", "error", err)
+	}
+	if err := c.Recon.GCNamespaceAgents(ctx, map[string]struct{}{}); err != nil {
+		c.Logger.Warnw("gc ns agents failed", "error", err)
+	}
 }
 
-func (c *Controller) reconcilePerNamespace(ctx context.Context, cfg *config.Config) {
+func (c *Controller) reconcilePerNamespace(ctx context.Context, cfg *config.Config) error {
 	// group matched PVCs by namespace and ensure one agent per namespace mounts all of them
 	targets, err := c.Disc.BuildTargets(ctx, cfg)
 	if err != nil {
-		c.Logger.Warnw("build targets failed", "error", err)
-		return
+		return err
 	}
-	nsToPvcs := map[string][]string{}
+	nsTargets := map[string][]Target{}
 	for _, t := range targets {
-		nsToPvcs[t.Namespace] = append(nsToPvcs[t.Namespace], t.PVCName)
-	}
-	for ns, pvcs := range nsToPvcs {
-		c.Logger.Infow("namespace agent desired", "namespace", ns, "pvcs", pvcs)
+		nsTargets[t.Namespace] = append(nsTargets[t.Namespace], t)
 	}
 	// GC all per-PVC agents when switching to per-namespace mode
 	if err := c.Recon.GCPerPVCAll(ctx); err != nil {
@@ -58,19 +247,20 @@ func (c *Controller) reconcilePerNamespace(ctx context.Context, cfg *config.Conf
 	}
 	// Ensure namespace agents and GC stale ones
 	keep := map[string]struct{}{}
-	for ns, pvcs := range nsToPvcs {
+	for ns, ts := range nsTargets {
 		keep[ns] = struct{}{}
-		if err := c.Recon.EnsureNamespaceAgent(ctx, ns, pvcs); err != nil {
+		if err := c.Recon.EnsureNamespaceAgent(ctx, cfg, ns, ts); err != nil {
 			c.Logger.Warnw("ns agent ensure failed", "ns", ns, "error", err)
 		}
 	}
 	if err := c.Recon.GCNamespaceAgents(ctx, keep); err != nil {
 		c.Logger.Warnw("gc ns agents failed", "error", err)
 	}
+	return nil
 }
 
-// StartPeriodic starts a background reconcile ticker.
-func (c *Controller) StartPeriodic(ctx context.Context, cfgProvider func() *config.Config, interval time.Duration) {
+// StartPeriodic queues a full reconcile every interval to self-heal.
+func (c *Controller) StartPeriodic(ctx context.Context, interval time.Duration) {
 	if interval <= 0 {
 		interval = time.Minute
 	}
@@ -82,21 +272,8 @@ func (c *Controller) StartPeriodic(ctx context.Context, cfgProvider func() *conf
 			case <-ctx.Done():
 				return
 			case <-t.C:
-				cfg := cfgProvider()
-				if cfg.Mode.DataPlane == "agent-per-namespace" {
-					c.reconcilePerNamespace(ctx, cfg)
-					continue
-				}
-				if cfg.Mode.DataPlane == "agent-per-pvc" {
-					targets, err := c.Disc.BuildTargets(ctx, cfg)
-					if err != nil {
-						c.Logger.Warnw("periodic build targets failed", "error", err)
-						continue
-					}
-					if err := c.Recon.Reconcile(ctx, targets); err != nil {
-						c.Logger.Warnw("periodic reconcile failed", "error", err)
-					}
-					continue
+				if c.IsLeader() {
+					c.workqueue().Add(keyAll)
 				}
 			}
 		}
@@ -0,0 +1,42 @@
+package backend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
+)
+
+func TestOnConfigChangeAppliesMountsSynchronously(t *testing.T) {
+	m := NewMountDataPlane("ns", zap.NewNop().Sugar())
+	c := &Controller{Mounts: m, Logger: zap.NewNop().Sugar()}
+	cfg := &config.Config{MountPVCs: []config.MountPVC{{PvcName: "a", MountPath: t.TempDir()}}}
+	cfg.Mode.DataPlane = ModeMountInBackend
+
+	c.OnConfigChange(context.Background(), cfg)
+	if _, ok := m.Lookup("ns", "a"); !ok {
+		t.Fatal("mount not served when OnConfigChange returned")
+	}
+
+	// a burst of reloads queues one resync
+	c.OnConfigChange(context.Background(), cfg)
+	q := c.workqueue()
+	deadline := time.Now().Add(5 * time.Second)
+	for q.Len() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no resync queued")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(2 * configDebounce)
+	if n := q.Len(); n != 1 {
+		t.Errorf("%d keys queued, want 1", n)
+	}
+	if k, _ := q.Get(); k != keyAll {
+		t.Errorf("queued %q, want %q", k, keyAll)
+	}
+	q.ShutDown()
+}
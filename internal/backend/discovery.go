@@ -2,9 +2,12 @@ package backend
 
 import (
 	"context"
+	"fmt"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
@@ -13,99 +16,257 @@ import (
 
 type Discovery struct {
 	Client kubernetes.Interface
+	// Mounts provides targets in mount-in-backend mode (the PVCs mounted into the backend Pod).
+	Mounts *MountDataPlane
+	// Cache serves namespaces, PVCs and PVs from informers; nil falls back to API calls.
+	Cache *Cache
+	// Policies are the PVCViewerPolicy objects merged with the cluster config; nil disables them.
+	Policies *Policies
 }
 
 // BuildTargets lists PVCs cluster-wide and applies matchers from cfg. If include lists are empty, returns empty.
+// In mount-in-backend mode the mounted PVCs are returned as-is.
 func (d *Discovery) BuildTargets(ctx context.Context, cfg *config.Config) ([]Target, error) {
-	// log inputs to aid troubleshooting
-	_ = cfg
-	nsMatch := matcher.New(cfg.Watch.Namespaces.Include, cfg.Watch.Namespaces.Exclude)
-	pvcMatch := matcher.New(cfg.Watch.Pvcs.Include, cfg.Watch.Pvcs.Exclude)
-	scMatch := matcher.New(cfg.Watch.StorageClasses.Include, cfg.Watch.StorageClasses.Exclude)
-
-	// If any include is empty -> treat as nothing per spec
-	if len(cfg.Watch.Namespaces.Include) == 0 || len(cfg.Watch.Pvcs.Include) == 0 || len(cfg.Watch.StorageClasses.Include) == 0 {
+	if cfg.Mode.DataPlane == ModeMountInBackend && d.Mounts != nil {
+		return d.Mounts.Targets(""), nil
+	}
+	// If the namespace include is empty -> treat as nothing per spec; empty PVC or storage class
+	// includes match nothing unless a PVCViewerPolicy provides its own
+	if len(cfg.Watch.Namespaces.Include) == 0 {
 		return []Target{}, nil
 	}
+	nsMatch, _, _, err := watchMatchers(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	nsl, err := d.Client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	namespaces, err := d.namespaces(ctx)
 	if err != nil {
 		return nil, err
 	}
 	out := []Target{}
-	for _, ns := range nsl.Items {
-		if !nsMatch.Match(ns.Name) {
+	for _, ns := range namespaces {
+		if !nsMatch.Match(ns.Name, ns.Labels, ns.Annotations) {
 			continue
 		}
-		pvcs, err := d.Client.CoreV1().PersistentVolumeClaims(ns.Name).List(ctx, metav1.ListOptions{})
+		rules, overrides, err := d.pvcRules(cfg, ns.Name)
 		if err != nil {
 			return nil, err
 		}
-		for _, pvc := range pvcs.Items {
-			if !pvcMatch.Match(pvc.Name) {
-				continue
-			}
-			// Require ReadWriteMany access; RWO cannot work with this architecture
-			if !hasRWX(pvc) {
-				continue
-			}
-			sc := ""
-			if pvc.Spec.StorageClassName != nil {
-				sc = *pvc.Spec.StorageClassName
-			}
-			// Fallback: some PVCs have nil StorageClassName; resolve from bound PV if possible
-			if sc == "" && pvc.Spec.VolumeName != "" {
-				if pv, err := d.Client.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{}); err == nil {
-					sc = pv.Spec.StorageClassName
-				}
-			}
-			if sc == "" || !scMatch.Match(sc) {
-				continue
-			}
-			out = append(out, Target{Namespace: ns.Name, PVCName: pvc.Name, StorageClass: sc})
+		targets, err := d.matchPVCs(ctx, ns.Name, rules, overrides)
+		if err != nil {
+			return nil, err
 		}
+		out = append(out, targets...)
 	}
 	return out, nil
 }
 
 // BuildTargetsForNamespace lists PVCs only in the given namespace and applies matchers from cfg.
 func (d *Discovery) BuildTargetsForNamespace(ctx context.Context, cfg *config.Config, nsName string) ([]Target, error) {
-	nsMatch := matcher.New(cfg.Watch.Namespaces.Include, cfg.Watch.Namespaces.Exclude)
-	pvcMatch := matcher.New(cfg.Watch.Pvcs.Include, cfg.Watch.Pvcs.Exclude)
-	scMatch := matcher.New(cfg.Watch.StorageClasses.Include, cfg.Watch.StorageClasses.Exclude)
+	if cfg.Mode.DataPlane == ModeMountInBackend && d.Mounts != nil {
+		return d.Mounts.Targets(nsName), nil
+	}
+	ok, err := d.namespaceWatched(ctx, cfg, nsName)
+	if err != nil || !ok {
+		return []Target{}, err
+	}
+	rules, overrides, err := d.pvcRules(cfg, nsName)
+	if err != nil {
+		return nil, err
+	}
+	return d.matchPVCs(ctx, nsName, rules, overrides)
+}
 
-	if len(cfg.Watch.Namespaces.Include) == 0 || len(cfg.Watch.Pvcs.Include) == 0 || len(cfg.Watch.StorageClasses.Include) == 0 {
-		return []Target{}, nil
+// namespaceWatched reports whether the cluster config's watch.namespaces selects nsName.
+func (d *Discovery) namespaceWatched(ctx context.Context, cfg *config.Config, nsName string) (bool, error) {
+	if len(cfg.Watch.Namespaces.Include) == 0 {
+		return false, nil
+	}
+	nsMatch, _, _, err := watchMatchers(cfg)
+	if err != nil {
+		return false, err
 	}
+	ns, err := d.getNamespace(ctx, nsName)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return nsMatch.Match(ns.Name, ns.Labels, ns.Annotations), nil
+}
 
-	if !nsMatch.Match(nsName) {
-		return []Target{}, nil
+// pvcRule selects PVCs in one namespace: the cluster watch.pvcs/storageClasses or one policy.
+type pvcRule struct {
+	source string
+	pvcs   matcher.ObjectMatcher
+	scs    matcher.Matcher
+}
+
+// policyRule builds the rule of p. The cluster watch.pvcs and watch.storageClasses excludes are
+// added to the policy's own, so a policy cannot bring back what the cluster config excludes.
+func policyRule(cfg *config.Config, p config.Policy) (pvcRule, error) {
+	pvcExclude := append(append([]string{}, p.Spec.Pvcs.Exclude...), cfg.Watch.Pvcs.Exclude...)
+	scExclude := append(append([]string{}, p.Spec.StorageClasses.Exclude...), cfg.Watch.StorageClasses.Exclude...)
+	pvcs, err := matcher.NewObject(p.Spec.Pvcs.Include, pvcExclude, p.Spec.Pvcs.Selector, p.Spec.Pvcs.OptIn)
+	if err != nil {
+		return pvcRule{}, err
+	}
+	return pvcRule{source: "PVCViewerPolicy " + p.Name, pvcs: pvcs, scs: matcher.New(p.Spec.StorageClasses.Include, scExclude)}, nil
+}
+
+// pvcRules returns the PVC rules for ns — its PVCViewerPolicies if it has any, else the cluster
+// watch rules — and the policies' security overrides (see config.PolicySpec for precedence).
+func (d *Discovery) pvcRules(cfg *config.Config, ns string) ([]pvcRule, []config.OverrideSpec, error) {
+	policies := d.Policies.ForNamespace(ns)
+	if len(policies) == 0 {
+		_, pvcMatch, scMatch, err := watchMatchers(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []pvcRule{{source: "watch", pvcs: pvcMatch, scs: scMatch}}, nil, nil
+	}
+	rules := make([]pvcRule, 0, len(policies))
+	for _, p := range policies {
+		r, err := policyRule(cfg, p)
+		if err != nil {
+			return nil, nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, policyOverrides(cfg, policies), nil
+}
+
+// policyOverrides concatenates the policies' security overrides if the cluster config allows them,
+// with IDs outside the agents.annotations bounds removed (see boundOverride).
+func policyOverrides(cfg *config.Config, policies []config.Policy) []config.OverrideSpec {
+	if !cfg.Policies.AllowSecurityOverrides {
+		return nil
+	}
+	var out []config.OverrideSpec
+	for _, p := range policies {
+		for _, o := range p.Spec.SecurityOverrides {
+			o, _ = boundOverride(cfg.Agents.Annotations, o)
+			out = append(out, o)
+		}
 	}
-	pvcs, err := d.Client.CoreV1().PersistentVolumeClaims(nsName).List(ctx, metav1.ListOptions{})
+	return out
+}
+
+// withOverrides sets t.Overrides from the policies in t's namespace.
+func (d *Discovery) withOverrides(cfg *config.Config, t Target) Target {
+	if cfg.Mode.DataPlane != ModeMountInBackend {
+		t.Overrides = policyOverrides(cfg, d.Policies.ForNamespace(t.Namespace))
+	}
+	return t
+}
+
+// watchMatchers builds the namespace, PVC and storage class matchers from cfg.Watch.
+func watchMatchers(cfg *config.Config) (nsMatch, pvcMatch matcher.ObjectMatcher, scMatch matcher.Matcher, err error) {
+	w := cfg.Watch
+	if nsMatch, err = matcher.NewObject(w.Namespaces.Include, w.Namespaces.Exclude, w.Namespaces.Selector, w.Namespaces.OptIn); err != nil {
+		return nsMatch, pvcMatch, scMatch, fmt.Errorf("watch.namespaces.selector: %w", err)
+	}
+	if pvcMatch, err = matcher.NewObject(w.Pvcs.Include, w.Pvcs.Exclude, w.Pvcs.Selector, w.Pvcs.OptIn); err != nil {
+		return nsMatch, pvcMatch, scMatch, fmt.Errorf("watch.pvcs.selector: %w", err)
+	}
+	return nsMatch, pvcMatch, matcher.New(w.StorageClasses.Include, w.StorageClasses.Exclude), nil
+}
+
+// matchPVCs returns the ReadWriteMany PVCs in ns that pass the PVC and storage class matchers of
+// any rule; overrides are attached to every target.
+func (d *Discovery) matchPVCs(ctx context.Context, ns string, rules []pvcRule, overrides []config.OverrideSpec) ([]Target, error) {
+	pvcs, err := d.pvcs(ctx, ns)
 	if err != nil {
 		return nil, err
 	}
 	out := []Target{}
-	for _, pvc := range pvcs.Items {
-		if !pvcMatch.Match(pvc.Name) {
+	for _, pvc := range pvcs {
+		// Require ReadWriteMany access; RWO cannot work with this architecture
+		if !hasRWX(*pvc) {
 			continue
 		}
-		if !hasRWX(pvc) {
+		sc := d.storageClassOf(ctx, pvc)
+		if sc == "" {
 			continue
 		}
-		sc := ""
-		if pvc.Spec.StorageClassName != nil {
-			sc = *pvc.Spec.StorageClassName
-		}
-		if sc == "" && pvc.Spec.VolumeName != "" {
-			if pv, err := d.Client.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{}); err == nil {
-				sc = pv.Spec.StorageClassName
+		for _, r := range rules {
+			if r.pvcs.Match(pvc.Name, pvc.Labels, pvc.Annotations) && r.scs.Match(sc) {
+				out = append(out, Target{Namespace: ns, PVCName: pvc.Name, StorageClass: sc, Labels: pvc.Labels, Annotations: pvc.Annotations, Overrides: overrides})
+				break
 			}
 		}
-		if sc == "" || !scMatch.Match(sc) {
-			continue
+	}
+	return out, nil
+}
+
+// Target returns ns/pvc with its storage class, labels, annotations and policy overrides under cfg,
+// without applying the watch matchers; PVC details stay empty if the PVC is unknown.
+func (d *Discovery) Target(ctx context.Context, cfg *config.Config, ns, pvc string) Target {
+	t := d.withOverrides(cfg, Target{Namespace: ns, PVCName: pvc})
+	p, err := d.getPVC(ctx, ns, pvc)
+	if err != nil {
+		return t
+	}
+	t.StorageClass, t.Labels, t.Annotations = d.storageClassOf(ctx, p), p.Labels, p.Annotations
+	return t
+}
+
+// storageClassOf falls back to the bound PV, since some PVCs have nil StorageClassName.
+func (d *Discovery) storageClassOf(ctx context.Context, pvc *corev1.PersistentVolumeClaim) string {
+	if pvc.Spec.StorageClassName != nil && *pvc.Spec.StorageClassName != "" {
+		return *pvc.Spec.StorageClassName
+	}
+	if pvc.Spec.VolumeName == "" {
+		return ""
+	}
+	if d.Cache != nil {
+		if pv, err := d.Cache.PVs.Get(pvc.Spec.VolumeName); err == nil {
+			return pv.Spec.StorageClassName
 		}
-		out = append(out, Target{Namespace: nsName, PVCName: pvc.Name, StorageClass: sc})
+		return ""
+	}
+	if pv, err := d.Client.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{}); err == nil {
+		return pv.Spec.StorageClassName
+	}
+	return ""
+}
+
+// namespaces lists namespaces from the cache, or from the API server without one.
+func (d *Discovery) namespaces(ctx context.Context) ([]*corev1.Namespace, error) {
+	if d.Cache != nil {
+		return d.Cache.Namespaces.List(labels.Everything())
+	}
+	nsl, err := d.Client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*corev1.Namespace, 0, len(nsl.Items))
+	for i := range nsl.Items {
+		out = append(out, &nsl.Items[i])
+	}
+	return out, nil
+}
+
+func (d *Discovery) getNamespace(ctx context.Context, name string) (*corev1.Namespace, error) {
+	if d.Cache != nil {
+		return d.Cache.Namespaces.Get(name)
+	}
+	return d.Client.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+}
+
+func (d *Discovery) pvcs(ctx context.Context, ns string) ([]*corev1.PersistentVolumeClaim, error) {
+	if d.Cache != nil {
+		return d.Cache.PVCs.PersistentVolumeClaims(ns).List(labels.Everything())
+	}
+	pvcs, err := d.Client.CoreV1().PersistentVolumeClaims(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*corev1.PersistentVolumeClaim, 0, len(pvcs.Items))
+	for i := range pvcs.Items {
+		out = append(out, &pvcs.Items[i])
 	}
 	return out, nil
 }
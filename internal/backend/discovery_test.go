@@ -0,0 +1,76 @@
+package backend
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
+)
+
+func TestPolicyRuleKeepsClusterExcludes(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Watch.Pvcs.Exclude = []string{"secret-*"}
+	cfg.Watch.StorageClasses.Exclude = []string{"local-*"}
+	pol := config.Policy{Name: "p", Spec: config.PolicySpec{
+		Pvcs:           config.WatchSet{Include: []string{"*"}, Exclude: []string{"tmp-*"}},
+		StorageClasses: config.WatchSet{Include: []string{"*"}},
+	}}
+	r, err := policyRule(cfg, pol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		pvc, sc string
+		want    bool
+	}{
+		{"data", "cephfs", true},
+		{"tmp-1", "cephfs", false},
+		{"secret-keys", "cephfs", false},
+		{"data", "local-path", false},
+	} {
+		if got := r.pvcs.Match(c.pvc, nil, nil) && r.scs.Match(c.sc); got != c.want {
+			t.Errorf("%s on %s: matched %v, want %v", c.pvc, c.sc, got, c.want)
+		}
+	}
+	if len(pol.Spec.Pvcs.Exclude) != 1 {
+		t.Error("policy spec modified")
+	}
+}
+
+func TestPolicyOverridesBounded(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Agents.Annotations.RunAsUser = config.IDRange{Min: i64(1000)}
+	cfg.Agents.Annotations.RunAsGroup = config.IDRange{Max: i64(60000)}
+	policies := []config.Policy{{Name: "a", Spec: config.PolicySpec{SecurityOverrides: []config.OverrideSpec{
+		{PvcMatch: "root", SecuritySpec: config.SecuritySpec{RunAsUser: i64(0), FSGroup: i64(0), SupplementalGroups: []int64{0, 2000}, ReadOnly: true}},
+		{PvcMatch: "low", SecuritySpec: config.SecuritySpec{RunAsUser: i64(999), RunAsGroup: i64(70000)}},
+		{PvcMatch: "ok", SecuritySpec: config.SecuritySpec{RunAsUser: i64(1500), RunAsGroup: i64(1500), FSGroup: i64(1500)}},
+		{PvcMatch: "tpl", PodTemplate: &config.PodTemplate{PriorityClassName: "system-node-critical"}},
+	}}}}
+
+	if got := policyOverrides(cfg, policies); got != nil {
+		t.Fatalf("overrides applied without policies.allowSecurityOverrides: %v", got)
+	}
+	cfg.Policies.AllowSecurityOverrides = true
+	got := policyOverrides(cfg, policies)
+	want := []config.OverrideSpec{
+		{PvcMatch: "root", SecuritySpec: config.SecuritySpec{SupplementalGroups: []int64{2000}, ReadOnly: true}},
+		{PvcMatch: "low"},
+		{PvcMatch: "ok", SecuritySpec: config.SecuritySpec{RunAsUser: i64(1500), RunAsGroup: i64(1500), FSGroup: i64(1500)}},
+		{PvcMatch: "tpl"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		for i := range got {
+			t.Errorf("override %d: %s, want %s", i, specString(got[i].SecuritySpec), specString(want[i].SecuritySpec))
+		}
+	}
+	if *policies[0].Spec.SecurityOverrides[0].RunAsUser != 0 {
+		t.Error("policy spec modified")
+	}
+	if _, rejected := boundOverride(cfg.Agents.Annotations, policies[0].Spec.SecurityOverrides[0]); len(rejected) != 3 {
+		t.Errorf("rejected %q, want runAsUser, fsGroup and supplemental group 0", rejected)
+	}
+	if _, rejected := boundOverride(cfg.Agents.Annotations, policies[0].Spec.SecurityOverrides[3]); len(rejected) != 1 {
+		t.Errorf("rejected %q, want the pod template", rejected)
+	}
+}
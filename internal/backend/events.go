@@ -0,0 +1,302 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/auth"
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
+)
+
+// Stream event types.
+const (
+	EventPVCAdded       = "pvc-added"
+	EventPVCRemoved     = "pvc-removed"
+	EventStatus         = "status"
+	EventConfigReloaded = "config-reloaded"
+)
+
+// Event is pushed to /api/v1/events subscribers. Namespace is empty for cluster-wide events.
+type Event struct {
+	Type      string        `json:"type"`
+	Time      time.Time     `json:"time"`
+	Namespace string        `json:"namespace,omitempty"`
+	PVC       string        `json:"pvc,omitempty"`
+	Status    *StatusReport `json:"status,omitempty"`
+}
+
+const subscriberBuffer = 64
+
+type subscriber struct {
+	namespaces map[string]bool // empty: all namespaces
+	ch         chan Event
+}
+
+func (s *subscriber) wants(ev Event) bool {
+	return ev.Namespace == "" || len(s.namespaces) == 0 || s.namespaces[ev.Namespace]
+}
+
+// Stream turns informer events and config reloads into PVC added/removed, agent status and
+// config events. It keeps the last known targets and statuses so only transitions are sent.
+type Stream struct {
+	Disc   *Discovery
+	Status *StatusService
+	Logger *zap.SugaredLogger
+
+	mu       sync.Mutex
+	subs     map[*subscriber]struct{}
+	targets  map[string]map[string]struct{} // ns -> pvcs
+	statuses map[string]StatusReport        // ns/pvc -> last status
+
+	queueOnce sync.Once
+	queue     workqueue.TypedInterface[string]
+}
+
+func (s *Stream) workqueue() workqueue.TypedInterface[string] {
+	s.queueOnce.Do(func() {
+		s.queue = workqueue.NewTyped[string]()
+	})
+	return s.queue
+}
+
+// Start registers informer handlers and processes changes until ctx is done. The cache is
+// started by the Controller.
+func (s *Stream) Start(ctx context.Context, cfgProvider func() *config.Config) {
+	q := s.workqueue()
+	go func() {
+		<-ctx.Done()
+		q.ShutDown()
+	}()
+	if ch := s.Disc.Cache; ch != nil {
+		enqueueNS := func(obj interface{}) {
+			if t, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = t.Obj
+			}
+			switch o := obj.(type) {
+			case *corev1.PersistentVolumeClaim:
+				q.Add(keyNSPrefix + o.Namespace)
+			case *corev1.Pod:
+				q.Add(keyNSPrefix + o.Namespace)
+			case *corev1.Namespace:
+				q.Add(keyNSPrefix + o.Name)
+			}
+		}
+		h := cache.ResourceEventHandlerFuncs{AddFunc: enqueueNS, UpdateFunc: func(_, n interface{}) { enqueueNS(n) }, DeleteFunc: enqueueNS}
+		_, _ = ch.PVCInformer.AddEventHandler(h)
+		_, _ = ch.PodInformer.AddEventHandler(h)
+		_, _ = ch.NamespaceInformer.AddEventHandler(h)
+	}
+	go func() {
+		for {
+			k, shutdown := q.Get()
+			if shutdown {
+				return
+			}
+			s.refresh(ctx, cfgProvider(), k)
+			q.Done(k)
+		}
+	}()
+	q.Add(keyAll)
+}
+
+// ConfigReloaded notifies subscribers and re-evaluates targets under the new config.
+func (s *Stream) ConfigReloaded() {
+	s.publish(Event{Type: EventConfigReloaded, Time: time.Now().UTC()})
+	s.workqueue().Add(keyAll)
+}
+
+// refresh diffs the targets (and, while anyone listens, statuses) of one namespace or all.
+func (s *Stream) refresh(ctx context.Context, cfg *config.Config, k string) {
+	var targets []Target
+	var err error
+	ns, one := strings.CutPrefix(k, keyNSPrefix)
+	if one {
+		targets, err = s.Disc.BuildTargetsForNamespace(ctx, cfg, ns)
+	} else {
+		targets, err = s.Disc.BuildTargets(ctx, cfg)
+	}
+	if err != nil {
+		s.Logger.Warnw("event stream: build targets failed", "error", err)
+		return
+	}
+	now := map[string]map[string]struct{}{}
+	for _, t := range targets {
+		if now[t.Namespace] == nil {
+			now[t.Namespace] = map[string]struct{}{}
+		}
+		now[t.Namespace][t.PVCName] = struct{}{}
+	}
+
+	s.mu.Lock()
+	if s.targets == nil {
+		s.targets = map[string]map[string]struct{}{}
+	}
+	// namespaces in scope of this refresh
+	scope := map[string]struct{}{}
+	if one {
+		scope[ns] = struct{}{}
+	} else {
+		for n := range s.targets {
+			scope[n] = struct{}{}
+		}
+		for n := range now {
+			scope[n] = struct{}{}
+		}
+	}
+	events := []Event{}
+	ts := time.Now().UTC()
+	for n := range scope {
+		for pvc := range now[n] {
+			if _, ok := s.targets[n][pvc]; !ok {
+				events = append(events, Event{Type: EventPVCAdded, Time: ts, Namespace: n, PVC: pvc})
+			}
+		}
+		for pvc := range s.targets[n] {
+			if _, ok := now[n][pvc]; !ok {
+				events = append(events, Event{Type: EventPVCRemoved, Time: ts, Namespace: n, PVC: pvc})
+				delete(s.statuses, n+"/"+pvc)
+			}
+		}
+		if len(now[n]) == 0 {
+			delete(s.targets, n)
+		} else {
+			s.targets[n] = now[n]
+		}
+	}
+	listening := len(s.subs) > 0
+	s.mu.Unlock()
+	for _, ev := range events {
+		s.publish(ev)
+	}
+	if !listening {
+		return
+	}
+
+	// status checks hit the API (events), so they only run while someone is subscribed
+	for _, t := range targets {
+		st, err := s.Status.GetStatus(ctx, cfg, t.Namespace, t.PVCName)
+		if err != nil {
+			continue
+		}
+		sk := key(t)
+		s.mu.Lock()
+		prev, seen := s.statuses[sk]
+		if s.statuses == nil {
+			s.statuses = map[string]StatusReport{}
+		}
+		s.statuses[sk] = st
+		s.mu.Unlock()
+		if !seen || prev != st {
+			st := st
+			s.publish(Event{Type: EventStatus, Time: time.Now().UTC(), Namespace: t.Namespace, PVC: t.PVCName, Status: &st})
+		}
+	}
+}
+
+func (s *Stream) publish(ev Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sub := range s.subs {
+		if !sub.wants(ev) {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+			// slow client: drop rather than block informers; it can resync via the list APIs
+		}
+	}
+}
+
+// subscribe registers a subscriber and queues the last known statuses of its namespaces.
+func (s *Stream) subscribe(namespaces []string) *subscriber {
+	sub := &subscriber{namespaces: map[string]bool{}, ch: make(chan Event, subscriberBuffer)}
+	for _, n := range namespaces {
+		sub.namespaces[n] = true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.subs == nil {
+		s.subs = map[*subscriber]struct{}{}
+	}
+	first := len(s.subs) == 0
+	s.subs[sub] = struct{}{}
+	if first {
+		// statuses were not tracked without listeners; compute them now
+		s.statuses = map[string]StatusReport{}
+		s.workqueue().Add(keyAll)
+		return sub
+	}
+	for k, st := range s.statuses {
+		ns, pvc, _ := strings.Cut(k, "/")
+		ev := Event{Type: EventStatus, Time: time.Now().UTC(), Namespace: ns, PVC: pvc, Status: &st}
+		if !sub.wants(ev) {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+		}
+	}
+	return sub
+}
+
+func (s *Stream) unsubscribe(sub *subscriber) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.subs, sub)
+}
+
+// Handler serves Server-Sent Events. Subscribe to namespaces with ?ns=a&ns=b (default: all);
+// PVC events are only sent for PVCs the caller may list.
+func (s *Stream) Handler(authz func() auth.Authorizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprint(w, "retry: 3000\n\n")
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		sub := s.subscribe(r.URL.Query()["ns"])
+		defer s.unsubscribe(sub)
+		id, _ := auth.FromContext(r.Context())
+		heartbeat := time.NewTicker(25 * time.Second)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-heartbeat.C:
+				_, _ = fmt.Fprint(w, ": keepalive\n\n")
+			case ev := <-sub.ch:
+				if ev.PVC != "" {
+					if ok, err := authz().Authorize(r.Context(), id, auth.VerbList, ev.Namespace, ev.PVC); err != nil || !ok {
+						continue
+					}
+				}
+				b, err := json.Marshal(ev)
+				if err != nil {
+					continue
+				}
+				_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, b)
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
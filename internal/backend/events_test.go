@@ -0,0 +1,103 @@
+package backend
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/auth"
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
+)
+
+func TestStreamHandler(t *testing.T) {
+	s := &Stream{Logger: zap.NewNop().Sugar()}
+	rbac := auth.NewRBAC([]config.RBACRule{{Namespaces: []string{"ns"}, PVCs: []string{"data-*"}, Verbs: []string{"list"}}})
+	returned := make(chan struct{})
+	h := s.Handler(func() auth.Authorizer { return rbac })
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(returned)
+		h(w, r)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"?ns=ns", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type %q", ct)
+	}
+	lines := bufio.NewReader(resp.Body)
+	readLine := func() string {
+		l, err := lines.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read stream: %v", err)
+		}
+		return strings.TrimSuffix(l, "\n")
+	}
+	if l := readLine(); l != "retry: 3000" {
+		t.Fatalf("first line %q", l)
+	}
+	readLine()
+	waitFor(t, "subscriber registered", func() bool { return subscribers(s) == 1 })
+
+	s.publish(Event{Type: EventPVCAdded, Namespace: "other", PVC: "data-1"}) // not subscribed
+	s.publish(Event{Type: EventPVCAdded, Namespace: "ns", PVC: "secret"})    // not listable
+	s.publish(Event{Type: EventPVCAdded, Time: time.Unix(100, 0).UTC(), Namespace: "ns", PVC: "data-1"})
+
+	if l := readLine(); l != "event: pvc-added" {
+		t.Fatalf("event line %q", l)
+	}
+	data, ok := strings.CutPrefix(readLine(), "data: ")
+	if !ok {
+		t.Fatalf("no data line")
+	}
+	var ev Event
+	if err := json.Unmarshal([]byte(data), &ev); err != nil {
+		t.Fatalf("data %q: %v", data, err)
+	}
+	if ev.Namespace != "ns" || ev.PVC != "data-1" || !ev.Time.Equal(time.Unix(100, 0)) {
+		t.Errorf("got %+v", ev)
+	}
+	if l := readLine(); l != "" {
+		t.Errorf("event not terminated by a blank line: %q", l)
+	}
+
+	cancel()
+	select {
+	case <-returned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler still running after the client disconnected")
+	}
+	if n := subscribers(s); n != 0 {
+		t.Errorf("%d subscribers left after disconnect", n)
+	}
+}
+
+func subscribers(s *Stream) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subs)
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting: %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,221 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/matcher"
+)
+
+// Check is one discovery rule evaluated for a PVC.
+type Check struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail"`
+}
+
+// Explanation reports why a PVC is (or is not) served and how it would be served.
+type Explanation struct {
+	Namespace    string               `json:"namespace"`
+	PVC          string               `json:"pvc"`
+	Mode         string               `json:"mode"`
+	Visible      bool                 `json:"visible"`
+	Checks       []Check              `json:"checks"`
+	StorageClass string               `json:"storageClass,omitempty"`
+	Security     *config.SecuritySpec `json:"security,omitempty"`
+	// Overrides are the matching security overrides in precedence order (see ResolveSecurity)
+	Overrides []AppliedOverride `json:"overrides,omitempty"`
+	// PodTemplate is the merged agent Pod template; omitted when empty
+	PodTemplate *config.PodTemplate `json:"podTemplate,omitempty"`
+	// RejectedAnnotations lists PVC security annotations not applied under agents.annotations
+	RejectedAnnotations []string `json:"rejectedAnnotations,omitempty"`
+	AgentService        string   `json:"agentService,omitempty"`
+}
+
+// AppliedOverride is a matching security override and where it is defined.
+type AppliedOverride struct {
+	// Source is "agents.securityOverrides[i]" or "PVCViewerPolicy <name> securityOverrides[i]"
+	Source   string              `json:"source"`
+	Override config.OverrideSpec `json:"override"`
+}
+
+// Explain evaluates every discovery rule for ns/pvc under cfg, the same way BuildTargetsForNamespace
+// does, but without stopping at the first rejection.
+func (d *Discovery) Explain(ctx context.Context, cfg *config.Config, ns, pvc string) (*Explanation, error) {
+	ex := &Explanation{Namespace: ns, PVC: pvc, Mode: cfg.Mode.DataPlane, Checks: []Check{}}
+	if cfg.Mode.DataPlane == ModeMountInBackend && d.Mounts != nil {
+		srv, ok := d.Mounts.Lookup(ns, pvc)
+		c := Check{Name: "mountPVCs", Passed: ok, Detail: "not listed in mountPVCs (or mount path missing in the backend Pod)"}
+		if ns != d.Mounts.Namespace {
+			c.Detail = fmt.Sprintf("mount-in-backend only serves PVCs in the backend namespace %q", d.Mounts.Namespace)
+		}
+		if ok {
+			c.Detail = "mounted into the backend Pod"
+			ex.Security = &config.SecuritySpec{ReadOnly: srv.ReadOnly}
+			ex.AgentService = "backend (in-process)"
+		}
+		ex.Checks = append(ex.Checks, c)
+		ex.Visible = ok
+		return ex, nil
+	}
+
+	nsMatch, pvcMatch, scMatch, err := watchMatchers(cfg)
+	if err != nil {
+		return nil, err
+	}
+	policies := d.Policies.ForNamespace(ns)
+	nsObj, err := d.getNamespace(ctx, ns)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	if nsObj == nil {
+		nsObj = &corev1.Namespace{}
+	}
+	ex.Checks = append(ex.Checks, objectCheck("watch.namespaces", nsMatch, ns, nsObj.Labels, nsObj.Annotations))
+
+	claim, err := d.getPVC(ctx, ns, pvc)
+	if apierrors.IsNotFound(err) {
+		if len(policies) == 0 {
+			ex.Checks = append(ex.Checks, objectCheck("watch.pvcs", pvcMatch, pvc, nil, nil))
+		}
+		ex.Checks = append(ex.Checks, Check{Name: "exists", Passed: false, Detail: "PersistentVolumeClaim not found"})
+		return ex, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(policies) == 0 {
+		ex.Checks = append(ex.Checks, objectCheck("watch.pvcs", pvcMatch, pvc, claim.Labels, claim.Annotations))
+	}
+	ex.Checks = append(ex.Checks, Check{Name: "exists", Passed: true, Detail: "PersistentVolumeClaim found"})
+
+	modes := make([]string, 0, len(claim.Spec.AccessModes))
+	for _, m := range claim.Spec.AccessModes {
+		modes = append(modes, string(m))
+	}
+	rwx := Check{Name: "accessModes", Passed: hasRWX(*claim), Detail: fmt.Sprintf("ReadWriteMany required; PVC has [%s]", strings.Join(modes, ", "))}
+	ex.Checks = append(ex.Checks, rwx)
+
+	sc := d.storageClassOf(ctx, claim)
+	ex.StorageClass = sc
+	scCheck := Check{Name: "storageClass", Passed: sc != "", Detail: "storage class " + sc}
+	switch {
+	case sc == "" && claim.Spec.VolumeName == "":
+		scCheck.Detail = "no storageClassName and no bound PV to resolve it from"
+	case sc == "":
+		scCheck.Detail = fmt.Sprintf("no storageClassName on the PVC or its PV %s", claim.Spec.VolumeName)
+	case claim.Spec.StorageClassName == nil || *claim.Spec.StorageClassName == "":
+		scCheck.Detail = fmt.Sprintf("storage class %s resolved from PV %s", sc, claim.Spec.VolumeName)
+	}
+	ex.Checks = append(ex.Checks, scCheck)
+	switch {
+	case len(policies) > 0:
+		ex.Checks = append(ex.Checks, policiesCheck(cfg, policies, claim, sc))
+	case sc != "":
+		ex.Checks = append(ex.Checks, globCheck("watch.storageClasses", scMatch, sc))
+	}
+
+	ex.Visible = true
+	for _, c := range ex.Checks {
+		ex.Visible = ex.Visible && c.Passed
+	}
+
+	t := Target{Namespace: ns, PVCName: pvc, StorageClass: sc, Labels: claim.Labels, Annotations: claim.Annotations, Overrides: policyOverrides(cfg, policies)}
+	overrides := withClusterOverrides(t, cfg.Agents.SecurityOverrides)
+	sec, matched := ResolveSecurity(cfg.Agents.SecurityDefaults, overrides, t)
+	for _, i := range matched {
+		ex.Overrides = append(ex.Overrides, AppliedOverride{Source: overrideSource(policies, len(t.Overrides), i), Override: overrides[i]})
+	}
+	ex.PodTemplate = podTemplateOrNil(ResolvePodTemplate(cfg.Agents.PodTemplate, overrides, matched))
+	sec, rejected := ApplyAnnotations(sec, cfg.Agents.Annotations, claim.Annotations)
+	ex.Security = &sec
+	if len(rejected) > 0 {
+		ex.RejectedAnnotations = rejected
+	}
+	ex.AgentService = AgentServiceName(cfg, t)
+	return ex, nil
+}
+
+// policiesCheck replaces watch.pvcs and watch.storageClasses in namespaces with PVCViewerPolicies;
+// the PVC passes if any policy matches it.
+func policiesCheck(cfg *config.Config, policies []config.Policy, claim *corev1.PersistentVolumeClaim, sc string) Check {
+	c := Check{Name: "policies"}
+	parts := []string{}
+	for _, p := range policies {
+		r, err := policyRule(cfg, p)
+		if err != nil {
+			continue
+		}
+		pc := objectCheck("pvcs", r.pvcs, claim.Name, claim.Labels, claim.Annotations)
+		sp := globCheck("storageClasses", r.scs, sc)
+		c.Passed = c.Passed || (pc.Passed && sp.Passed)
+		parts = append(parts, fmt.Sprintf("%s: %s; storage class %s", p.Name, pc.Detail, sp.Detail))
+	}
+	c.Detail = strings.Join(parts, " | ")
+	return c
+}
+
+// overrideSource names the idx-th entry of policyOverrides(policies) followed by agents.securityOverrides.
+func overrideSource(policies []config.Policy, nPolicy, idx int) string {
+	if idx >= nPolicy {
+		return fmt.Sprintf("agents.securityOverrides[%d]", idx-nPolicy)
+	}
+	for _, p := range policies {
+		if idx < len(p.Spec.SecurityOverrides) {
+			return fmt.Sprintf("PVCViewerPolicy %s securityOverrides[%d]", p.Name, idx)
+		}
+		idx -= len(p.Spec.SecurityOverrides)
+	}
+	return ""
+}
+
+func globCheck(name string, m matcher.Matcher, s string) Check {
+	r := m.Explain(s)
+	return Check{Name: name, Passed: r.Matched, Detail: globDetail(m, r, s)}
+}
+
+// objectCheck extends the glob detail with label selector and enabled-annotation results.
+func objectCheck(name string, m matcher.ObjectMatcher, s string, lbls, annotations map[string]string) Check {
+	r := m.Explain(s, lbls, annotations)
+	parts := []string{globDetail(m.Names, r.Names, s)}
+	if r.Selector != "" {
+		if r.SelectorMatched {
+			parts = append(parts, fmt.Sprintf("labels match selector %q", r.Selector))
+		} else {
+			parts = append(parts, fmt.Sprintf("labels do not match selector %q", r.Selector))
+		}
+	}
+	if r.OptedOut {
+		parts = append(parts, fmt.Sprintf("opted out by annotation %s=false", matcher.AnnotationEnabled))
+	}
+	if r.OptInMissing {
+		parts = append(parts, fmt.Sprintf("opt-in required: annotation %s=true missing", matcher.AnnotationEnabled))
+	}
+	return Check{Name: name, Passed: r.Matched, Detail: strings.Join(parts, "; ")}
+}
+
+func globDetail(m matcher.Matcher, r matcher.Result, s string) string {
+	switch {
+	case m.Empty():
+		return "include list is empty; nothing matches"
+	case r.Matched:
+		return fmt.Sprintf("%q included by %q", s, r.Include)
+	case r.Exclude != "":
+		return fmt.Sprintf("%q included by %q but excluded by %q", s, r.Include, r.Exclude)
+	default:
+		return fmt.Sprintf("%q matches no include pattern", s)
+	}
+}
+
+func (d *Discovery) getPVC(ctx context.Context, ns, pvc string) (*corev1.PersistentVolumeClaim, error) {
+	if d.Cache != nil {
+		return d.Cache.PVCs.PersistentVolumeClaims(ns).Get(pvc)
+	}
+	return d.Client.CoreV1().PersistentVolumeClaims(ns).Get(ctx, pvc, metav1.GetOptions{})
+}
@@ -0,0 +1,69 @@
+package backend
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
+)
+
+// LeaseName is the coordination.k8s.io Lease used for backend leader election.
+const LeaseName = "pvc-viewer-backend"
+
+// RunLeaderElection contends for the Lease in ns until ctx is done. Only the leader reconciles
+// and garbage-collects agents; every replica keeps serving the API. On gaining leadership a
+// full reconcile of the current config is triggered. The Lease is released when ctx ends so a
+// rolling update hands over quickly.
+func (c *Controller) RunLeaderElection(ctx context.Context, client kubernetes.Interface, ns, identity string, cfgProvider func() *config.Config) error {
+	c.electionEnabled.Store(true)
+	setLeader(false)
+	lock, err := resourcelock.New(resourcelock.LeasesResourceLock, ns, LeaseName, client.CoreV1(), client.CoordinationV1(), resourcelock.ResourceLockConfig{Identity: identity})
+	if err != nil {
+		return err
+	}
+	le, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   15 * time.Second,
+		RenewDeadline:   10 * time.Second,
+		RetryPeriod:     2 * time.Second,
+		ReleaseOnCancel: true,
+		Name:            LeaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(lctx context.Context) {
+				c.Logger.Infow("acquired leadership", "identity", identity)
+				c.leader.Store(true)
+				setLeader(true)
+				c.OnConfigChange(lctx, cfgProvider())
+			},
+			OnStoppedLeading: func() {
+				c.Logger.Infow("lost leadership", "identity", identity)
+				c.leader.Store(false)
+				setLeader(false)
+			},
+			OnNewLeader: func(id string) {
+				if id != identity {
+					c.Logger.Infow("current leader", "leader", id)
+				}
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	go func() {
+		// Run returns when leadership is lost; keep contending until shutdown
+		for ctx.Err() == nil {
+			le.Run(ctx)
+		}
+	}()
+	return nil
+}
+
+// IsLeader reports whether this replica may reconcile. Without leader election every replica leads.
+func (c *Controller) IsLeader() bool {
+	return !c.electionEnabled.Load() || c.leader.Load()
+}
@@ -0,0 +1,78 @@
+package backend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
+)
+
+func TestFollowerDropsQueuedKeys(t *testing.T) {
+	c := &Controller{Logger: zap.NewNop().Sugar()}
+	c.electionEnabled.Store(true)
+	q := c.workqueue()
+	defer q.ShutDown()
+	cfgProvider := func() *config.Config {
+		t.Error("follower reconciled")
+		return &config.Config{}
+	}
+
+	q.Add(keyAll)
+	q.Add(keyPVCPrefix + "ns/data")
+	for q.Len() > 0 {
+		if !c.processNext(context.Background(), cfgProvider) {
+			t.Fatal("queue shut down")
+		}
+	}
+	if n := q.NumRequeues(keyAll); n != 0 {
+		t.Errorf("dropped key requeued %d times", n)
+	}
+
+	// the periodic resync only queues on the leader
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.StartPeriodic(ctx, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	if n := q.Len(); n != 0 {
+		t.Fatalf("follower queued %d periodic resyncs", n)
+	}
+	c.leader.Store(true)
+	waitFor(t, "periodic resync on the leader", func() bool { return q.Len() > 0 })
+	if k, _ := q.Get(); k != keyAll {
+		t.Errorf("queued %q, want %q", k, keyAll)
+	}
+}
+
+func TestGainingLeadershipQueuesFullReconcile(t *testing.T) {
+	c := &Controller{Logger: zap.NewNop().Sugar()}
+	q := c.workqueue()
+	defer q.ShutDown()
+	if !c.IsLeader() {
+		t.Fatal("without leader election every replica leads")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := c.RunLeaderElection(ctx, fake.NewSimpleClientset(), "ns", "replica-1", func() *config.Config { return &config.Config{} }); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "leadership", c.IsLeader)
+	waitFor(t, "full reconcile queued", func() bool { return q.Len() > 0 })
+	if k, _ := q.Get(); k != keyAll {
+		t.Errorf("queued %q, want %q", k, keyAll)
+	}
+	q.Done(keyAll)
+
+	c.leader.Store(false)
+	q.Add(keyNSPrefix + "ns")
+	if !c.processNext(context.Background(), func() *config.Config {
+		t.Error("replica reconciled after losing leadership")
+		return &config.Config{}
+	}) {
+		t.Fatal("queue shut down")
+	}
+}
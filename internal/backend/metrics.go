@@ -3,8 +3,46 @@ package backend
 import (
 	"net/http"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	promhttp "github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-func MetricsHandler() http.Handler { return promhttp.Handler() }
+var leaderGauge = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "pvc_viewer_leader",
+	Help: "1 if this backend replica holds the reconcile lease (or leader election is disabled).",
+})
+
+func init() { leaderGauge.Set(1) }
 
+func setLeader(b bool) {
+	if b {
+		leaderGauge.Set(1)
+	} else {
+		leaderGauge.Set(0)
+	}
+}
+
+var (
+	configReloads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pvc_viewer_config_reloads_total",
+		Help: "Config loads by result (applied or rejected).",
+	}, []string{"result"})
+	configValid = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pvc_viewer_config_last_reload_successful",
+		Help: "1 if the last config load was applied, 0 if it was rejected and the previous config is still in effect.",
+	})
+)
+
+// RecordConfigReload updates the config reload metrics; err is nil for an applied config.
+func RecordConfigReload(err error) {
+	if err != nil {
+		configReloads.WithLabelValues("rejected").Inc()
+		configValid.Set(0)
+		return
+	}
+	configReloads.WithLabelValues("applied").Inc()
+	configValid.Set(1)
+}
+
+func MetricsHandler() http.Handler { return promhttp.Handler() }
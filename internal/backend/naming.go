@@ -3,6 +3,8 @@ package backend
 import (
 	"crypto/sha1"
 	"encoding/hex"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
 )
 
 // NamespaceAgentName returns a deterministic name for a namespace-scoped agent
@@ -20,3 +22,12 @@ func NamespaceAgentGroupName(ns, profileHash string) string {
 	base := sha1.Sum([]byte(ns))
 	return "pvc-viewer-agent-ns-" + hex.EncodeToString(base[:4]) + "-" + profileHash
 }
+
+// AgentServiceName returns the agent Service (and Pod) serving ns/pvc under cfg's data plane mode.
+// In agent-per-namespace mode it is the group agent of the PVC's effective security profile and Pod template.
+func AgentServiceName(cfg *config.Config, t Target) string {
+	if cfg != nil && cfg.Mode.DataPlane == "agent-per-namespace" {
+		return NamespaceAgentGroupName(t.Namespace, GroupKey(EffectiveProfile(cfg, t)))
+	}
+	return AgentName(t.Namespace, t.PVCName)
+}
@@ -0,0 +1,95 @@
+package backend
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Agent objects live in many namespaces, so they cannot be owned by the (namespaced) backend
+// Deployment. Instead the chart installs an empty, cluster-scoped ClusterRole as anchor: agent
+// Pods, Services and token Secrets reference it, and Kubernetes GC removes them on uninstall.
+
+// Cleanup-on-shutdown policies (agents.cleanupOnShutdown).
+const (
+	CleanupNever     = "never"     // default: rolling updates keep agents; uninstall relies on ownerReferences
+	CleanupAlways    = "always"    // legacy: delete all agents whenever the leader terminates
+	CleanupUninstall = "uninstall" // delete agents only if the owner anchor is gone or being deleted
+)
+
+// ResolveOwnerAnchor returns an ownerReference to the anchor ClusterRole.
+func ResolveOwnerAnchor(ctx context.Context, client kubernetes.Interface, name string) (*metav1.OwnerReference, error) {
+	cr, err := client.RbacV1().ClusterRoles().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return &metav1.OwnerReference{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole", Name: cr.Name, UID: cr.UID}, nil
+}
+
+// AnchorGone reports whether the owner anchor was deleted (or is being deleted), i.e. the
+// release is being uninstalled. Without an anchor it returns false.
+func (r *Reconciler) AnchorGone(ctx context.Context) bool {
+	if r.Owner == nil {
+		return false
+	}
+	cr, err := r.Client.RbacV1().ClusterRoles().Get(ctx, r.Owner.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return true
+	}
+	return err == nil && (cr.DeletionTimestamp != nil || cr.UID != r.Owner.UID)
+}
+
+func (r *Reconciler) ownerRefs() []metav1.OwnerReference {
+	if r.Owner == nil {
+		return nil
+	}
+	return []metav1.OwnerReference{*r.Owner}
+}
+
+func (r *Reconciler) ownerUID() string {
+	if r.Owner == nil {
+		return ""
+	}
+	return string(r.Owner.UID)
+}
+
+func hasOwner(refs []metav1.OwnerReference, owner *metav1.OwnerReference) bool {
+	if owner == nil {
+		return true
+	}
+	for _, o := range refs {
+		if o.UID == owner.UID {
+			return true
+		}
+	}
+	return false
+}
+
+// ensureService creates svc, or adopts an existing one that predates the owner anchor.
+func (r *Reconciler) ensureService(ctx context.Context, svc *corev1.Service) (created bool, err error) {
+	svc.OwnerReferences = r.ownerRefs()
+	existing, err := r.agentService(ctx, svc.Namespace, svc.Name)
+	if apierrors.IsNotFound(err) {
+		_, err := r.Client.CoreV1().Services(svc.Namespace).Create(ctx, svc, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			// the cache lags behind the API; adopt it on the next sync
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !hasOwner(existing.OwnerReferences, r.Owner) {
+		existing = existing.DeepCopy()
+		existing.OwnerReferences = append(existing.OwnerReferences, *r.Owner)
+		_, err = r.Client.CoreV1().Services(svc.Namespace).Update(ctx, existing, metav1.UpdateOptions{})
+	}
+	return false, err
+}
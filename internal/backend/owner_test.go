@@ -0,0 +1,72 @@
+package backend
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestEnsureServiceAdopts(t *testing.T) {
+	owner := &metav1.OwnerReference{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole", Name: "pvc-viewer-anchor", UID: "anchor-uid"}
+	other := metav1.OwnerReference{APIVersion: "v1", Kind: "ConfigMap", Name: "x", UID: "x-uid"}
+	tests := []struct {
+		name        string
+		existing    *corev1.Service
+		forbidden   bool
+		wantCreated bool
+		wantErr     bool
+		wantOwners  int
+		wantUpdate  bool
+	}{
+		{name: "created", wantCreated: true, wantOwners: 1},
+		{name: "legacy adopted", existing: &corev1.Service{ObjectMeta: metav1.ObjectMeta{OwnerReferences: []metav1.OwnerReference{other}}}, wantOwners: 2, wantUpdate: true},
+		{name: "already owned", existing: &corev1.Service{ObjectMeta: metav1.ObjectMeta{OwnerReferences: []metav1.OwnerReference{*owner}}}, wantOwners: 1},
+		{name: "adoption forbidden", existing: &corev1.Service{}, forbidden: true, wantErr: true, wantUpdate: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			if tt.existing != nil {
+				tt.existing.Name, tt.existing.Namespace = "agent", "ns"
+				if _, err := client.CoreV1().Services("ns").Create(context.Background(), tt.existing, metav1.CreateOptions{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.forbidden {
+				client.PrependReactor("update", "services", func(k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "services"}, "agent", nil)
+				})
+			}
+			client.ClearActions()
+			r := &Reconciler{Client: client, Owner: owner}
+			created, err := r.ensureService(context.Background(), &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "ns"}})
+			if created != tt.wantCreated || (err != nil) != tt.wantErr {
+				t.Fatalf("created %t err %v, want %t and error %t", created, err, tt.wantCreated, tt.wantErr)
+			}
+			updated := false
+			for _, a := range client.Actions() {
+				updated = updated || a.GetVerb() == "update"
+			}
+			if updated != tt.wantUpdate {
+				t.Errorf("update sent %t, want %t", updated, tt.wantUpdate)
+			}
+			if tt.wantErr {
+				return
+			}
+			svc, err := client.CoreV1().Services("ns").Get(context.Background(), "agent", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(svc.OwnerReferences) != tt.wantOwners || !hasOwner(svc.OwnerReferences, owner) {
+				t.Errorf("owners %v, want %d including the anchor", svc.OwnerReferences, tt.wantOwners)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package backend
+
+import (
+	"context"
+	"sort"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
+)
+
+// PlannedAgent is an agent Pod/Service a config would produce.
+type PlannedAgent struct {
+	Name      string              `json:"name"`
+	Namespace string              `json:"namespace"`
+	PVCs      []string            `json:"pvcs"`
+	Security  config.SecuritySpec `json:"security"`
+	// PodTemplate is the merged agents.podTemplate; omitted when empty
+	PodTemplate *config.PodTemplate `json:"podTemplate,omitempty"`
+}
+
+// Plan is what the reconciler would converge to under a config.
+type Plan struct {
+	Mode    string         `json:"mode"`
+	Targets []Target       `json:"targets"`
+	Agents  []PlannedAgent `json:"agents"`
+}
+
+// Plan computes targets and agents for cfg against the current cluster state without applying
+// anything (config dry-run).
+func (d *Discovery) Plan(ctx context.Context, cfg *config.Config) (*Plan, error) {
+	p := &Plan{Mode: cfg.Mode.DataPlane, Targets: []Target{}, Agents: []PlannedAgent{}}
+	if cfg.Mode.DataPlane == ModeMountInBackend {
+		// served in-process; mountPVCs only take effect after a backend rollout
+		ns := ""
+		if d.Mounts != nil {
+			ns = d.Mounts.Namespace
+		}
+		for _, m := range cfg.MountPVCs {
+			p.Targets = append(p.Targets, Target{Namespace: ns, PVCName: m.PvcName})
+		}
+		return p, nil
+	}
+	targets, err := d.BuildTargets(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	p.Targets = append(p.Targets, targets...)
+	switch cfg.Mode.DataPlane {
+	case "agent-per-pvc":
+		for _, t := range targets {
+			sec, tpl := EffectiveProfile(cfg, t)
+			p.Agents = append(p.Agents, PlannedAgent{Name: AgentName(t.Namespace, t.PVCName), Namespace: t.Namespace, PVCs: []string{t.PVCName}, Security: sec, PodTemplate: podTemplateOrNil(tpl)})
+		}
+	case "agent-per-namespace":
+		groups := map[string]*PlannedAgent{}
+		for _, t := range targets {
+			sec, tpl := EffectiveProfile(cfg, t)
+			name := NamespaceAgentGroupName(t.Namespace, GroupKey(sec, tpl))
+			if groups[name] == nil {
+				groups[name] = &PlannedAgent{Name: name, Namespace: t.Namespace, Security: sec, PodTemplate: podTemplateOrNil(tpl)}
+			}
+			groups[name].PVCs = append(groups[name].PVCs, t.PVCName)
+		}
+		for _, a := range groups {
+			sort.Strings(a.PVCs)
+			p.Agents = append(p.Agents, *a)
+		}
+	}
+	sort.Slice(p.Agents, func(i, j int) bool {
+		if p.Agents[i].Namespace != p.Agents[j].Namespace {
+			return p.Agents[i].Namespace < p.Agents[j].Namespace
+		}
+		return p.Agents[i].Name < p.Agents[j].Name
+	})
+	return p, nil
+}
+
+func podTemplateOrNil(tpl config.PodTemplate) *config.PodTemplate {
+	if tpl.Key() == "" {
+		return nil
+	}
+	return &tpl
+}
@@ -0,0 +1,50 @@
+package backend
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
+)
+
+// applyPodTemplate adds tpl to an agent Pod. Labels and annotations the backend set (app,
+// pvcviewer.k8s.io/*, the spec hash) are never overwritten.
+func applyPodTemplate(pod *corev1.Pod, tpl config.PodTemplate) {
+	if tpl.Resources != nil {
+		for i := range pod.Spec.Containers {
+			pod.Spec.Containers[i].Resources = *tpl.Resources
+		}
+	}
+	pod.Spec.Tolerations = tpl.Tolerations
+	pod.Spec.NodeSelector = tpl.NodeSelector
+	pod.Spec.Affinity = tpl.Affinity
+	pod.Spec.PriorityClassName = tpl.PriorityClassName
+	for _, s := range tpl.ImagePullSecrets {
+		pod.Spec.ImagePullSecrets = append(pod.Spec.ImagePullSecrets, corev1.LocalObjectReference{Name: s})
+	}
+	pod.Labels = addMissing(pod.Labels, tpl.Labels)
+	pod.Annotations = addMissing(pod.Annotations, tpl.Annotations)
+}
+
+// templateHashPart extends an agent spec hash input with tpl; empty for no template so agents
+// created before podTemplate existed keep their hash.
+func templateHashPart(tpl config.PodTemplate) string {
+	if k := tpl.Key(); k != "" {
+		return "|tpl=" + k
+	}
+	return ""
+}
+
+// addMissing copies the keys of extra that dst does not have yet; dst is copied, not modified.
+func addMissing(dst, extra map[string]string) map[string]string {
+	if len(extra) == 0 {
+		return dst
+	}
+	out := make(map[string]string, len(dst)+len(extra))
+	for k, v := range extra {
+		out[k] = v
+	}
+	for k, v := range dst {
+		out[k] = v
+	}
+	return out
+}
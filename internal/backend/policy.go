@@ -0,0 +1,244 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
+)
+
+// PolicyGVR is the namespaced PVCViewerPolicy resource (CRD in the Helm chart).
+var PolicyGVR = schema.GroupVersionResource{Group: "pvcviewer.k8s.io", Version: "v1alpha1", Resource: "pvcviewerpolicies"}
+
+// Policy status condition types.
+const (
+	ConditionAccepted    = "Accepted"
+	ConditionPVCsMatched = "PVCsMatched"
+	ConditionAgentsReady = "AgentsReady"
+)
+
+// PolicyStatus is written to status of PVCViewerPolicy objects.
+type PolicyStatus struct {
+	ObservedGeneration int64              `json:"observedGeneration,omitempty"`
+	MatchedPVCs        []string           `json:"matchedPVCs"`
+	Conditions         []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// Policies watches PVCViewerPolicy objects through a dynamic informer.
+type Policies struct {
+	Client   dynamic.Interface
+	Informer cache.SharedIndexInformer
+
+	factory dynamicinformer.DynamicSharedInformerFactory
+	lister  cache.GenericLister
+}
+
+func NewPolicies(client dynamic.Interface, resync time.Duration) *Policies {
+	f := dynamicinformer.NewDynamicSharedInformerFactory(client, resync)
+	inf := f.ForResource(PolicyGVR)
+	return &Policies{Client: client, Informer: inf.Informer(), factory: f, lister: inf.Lister()}
+}
+
+// Start runs the informer and blocks until it is synced.
+func (p *Policies) Start(ctx context.Context) error {
+	p.factory.Start(ctx.Done())
+	for t, ok := range p.factory.WaitForCacheSync(ctx.Done()) {
+		if !ok {
+			return fmt.Errorf("cache sync failed for %v", t)
+		}
+	}
+	return nil
+}
+
+// ForNamespace returns the valid policies in ns, sorted by name. A nil Policies has none.
+func (p *Policies) ForNamespace(ns string) []config.Policy {
+	if p == nil {
+		return nil
+	}
+	objs, err := p.lister.ByNamespace(ns).List(labels.Everything())
+	if err != nil {
+		return nil
+	}
+	out := []config.Policy{}
+	for _, o := range objs {
+		if pol, err := decodePolicy(o); err == nil {
+			out = append(out, pol)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Namespaces returns the namespaces holding at least one policy, valid or not.
+func (p *Policies) Namespaces() []string {
+	if p == nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	out := []string{}
+	for _, k := range p.Informer.GetStore().ListKeys() {
+		ns, _, err := cache.SplitMetaNamespaceKey(k)
+		if err == nil && !seen[ns] {
+			seen[ns] = true
+			out = append(out, ns)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// decodePolicy converts and validates a PVCViewerPolicy object.
+func decodePolicy(obj runtime.Object) (config.Policy, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return config.Policy{}, fmt.Errorf("unexpected object %T", obj)
+	}
+	pol := config.Policy{Namespace: u.GetNamespace(), Name: u.GetName(), Generation: u.GetGeneration()}
+	if spec, ok := u.Object["spec"].(map[string]interface{}); ok {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(spec, &pol.Spec); err != nil {
+			return pol, err
+		}
+	}
+	return pol, pol.Spec.Validate()
+}
+
+// syncPolicyStatus refreshes the status of the policies affected by work queue key k.
+func (c *Controller) syncPolicyStatus(ctx context.Context, cfg *config.Config, k string) {
+	if c.Disc.Policies == nil {
+		return
+	}
+	if k == keyAll {
+		for _, ns := range c.Disc.Policies.Namespaces() {
+			c.updatePolicyStatus(ctx, cfg, ns)
+		}
+		return
+	}
+	if ns, ok := strings.CutPrefix(k, keyNSPrefix); ok {
+		c.updatePolicyStatus(ctx, cfg, ns)
+	} else if nsName, ok := strings.CutPrefix(k, keyPVCPrefix); ok {
+		ns, _, _ := strings.Cut(nsName, "/")
+		c.updatePolicyStatus(ctx, cfg, ns)
+	}
+}
+
+// updatePolicyStatus recomputes the status of every policy in ns under cfg and writes the ones that
+// changed. Only the leader calls it.
+func (c *Controller) updatePolicyStatus(ctx context.Context, cfg *config.Config, ns string) {
+	p := c.Disc.Policies
+	if p == nil {
+		return
+	}
+	objs, err := p.lister.ByNamespace(ns).List(labels.Everything())
+	if err != nil || len(objs) == 0 {
+		return
+	}
+	nsWatched, err := c.Disc.namespaceWatched(ctx, cfg, ns)
+	if err != nil {
+		return
+	}
+	for _, o := range objs {
+		u, ok := o.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		pol, decodeErr := decodePolicy(u)
+		st := PolicyStatus{MatchedPVCs: []string{}}
+		if raw, ok := u.Object["status"].(map[string]interface{}); ok {
+			_ = runtime.DefaultUnstructuredConverter.FromUnstructured(raw, &st)
+		}
+		if st.MatchedPVCs == nil {
+			st.MatchedPVCs = []string{}
+		}
+		prev := st
+		prev.Conditions = append([]metav1.Condition{}, st.Conditions...)
+		st.ObservedGeneration = u.GetGeneration()
+		st.MatchedPVCs = []string{}
+
+		accepted := metav1.Condition{Type: ConditionAccepted, Status: metav1.ConditionTrue, Reason: "Valid", Message: "policy is applied", ObservedGeneration: st.ObservedGeneration}
+		switch {
+		case decodeErr != nil:
+			accepted.Status, accepted.Reason, accepted.Message = metav1.ConditionFalse, "InvalidSpec", decodeErr.Error()
+		case cfg.Mode.DataPlane == ModeMountInBackend:
+			accepted.Status, accepted.Reason, accepted.Message = metav1.ConditionFalse, "MountInBackend", "policies are ignored in mount-in-backend mode"
+		case !nsWatched:
+			accepted.Status, accepted.Reason, accepted.Message = metav1.ConditionFalse, "NamespaceNotWatched", "namespace is not selected by the cluster config watch.namespaces"
+		case len(pol.Spec.SecurityOverrides) > 0 && !cfg.Policies.AllowSecurityOverrides:
+			accepted.Message = "policy is applied; spec.securityOverrides ignored (policies.allowSecurityOverrides is off)"
+		default:
+			var rejected []string
+			for i, o := range pol.Spec.SecurityOverrides {
+				_, r := boundOverride(cfg.Agents.Annotations, o)
+				for _, msg := range r {
+					rejected = append(rejected, fmt.Sprintf("spec.securityOverrides[%d].%s", i, msg))
+				}
+			}
+			if len(rejected) > 0 {
+				accepted.Message = "policy is applied; ignored: " + strings.Join(rejected, "; ")
+			}
+		}
+		apimeta.SetStatusCondition(&st.Conditions, accepted)
+
+		var targets []Target
+		if accepted.Status == metav1.ConditionTrue {
+			if rule, err := policyRule(cfg, pol); err == nil {
+				targets, _ = c.Disc.matchPVCs(ctx, ns, []pvcRule{rule}, nil)
+			}
+		}
+		for _, t := range targets {
+			st.MatchedPVCs = append(st.MatchedPVCs, t.PVCName)
+		}
+		matched := metav1.Condition{Type: ConditionPVCsMatched, Status: metav1.ConditionTrue, Reason: "Matched", Message: fmt.Sprintf("%d PVCs matched", len(targets)), ObservedGeneration: st.ObservedGeneration}
+		if len(targets) == 0 {
+			matched.Status, matched.Reason, matched.Message = metav1.ConditionFalse, "NoMatch", "no ReadWriteMany PVC matches the policy"
+		}
+		apimeta.SetStatusCondition(&st.Conditions, matched)
+
+		ready := metav1.Condition{Type: ConditionAgentsReady, Status: metav1.ConditionTrue, Reason: "AgentsReady", Message: "all agents are ready", ObservedGeneration: st.ObservedGeneration}
+		notReady := []string{}
+		for _, t := range targets {
+			if name := AgentServiceName(cfg, c.Disc.withOverrides(cfg, t)); !c.agentReady(ctx, ns, name) {
+				notReady = append(notReady, t.PVCName)
+			}
+		}
+		switch {
+		case len(targets) == 0:
+			ready.Status, ready.Reason, ready.Message = metav1.ConditionFalse, "NoAgents", "no PVCs matched"
+		case len(notReady) > 0:
+			ready.Status, ready.Reason, ready.Message = metav1.ConditionFalse, "AgentsNotReady", fmt.Sprintf("agents not ready for %v", notReady)
+		}
+		apimeta.SetStatusCondition(&st.Conditions, ready)
+
+		if reflect.DeepEqual(prev, st) {
+			continue
+		}
+		raw, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&st)
+		if err != nil {
+			continue
+		}
+		u = u.DeepCopy()
+		u.Object["status"] = raw
+		if _, err := p.Client.Resource(PolicyGVR).Namespace(ns).UpdateStatus(ctx, u, metav1.UpdateOptions{}); err != nil {
+			c.Logger.Warnw("policy status update failed", "ns", ns, "policy", u.GetName(), "error", err)
+		}
+	}
+}
+
+// agentReady reports whether the agent Pod name in ns exists and is Ready.
+func (c *Controller) agentReady(ctx context.Context, ns, name string) bool {
+	pod, err := (&StatusService{Client: c.Disc.Client, Disc: c.Disc}).getAgentPod(ctx, ns, name)
+	return err == nil && pod.DeletionTimestamp == nil && podReady(pod)
+}
@@ -14,10 +14,13 @@ import (
 type AgentProxy struct {
 	Client kubernetes.Interface
 	HTTP   *http.Client
+	// Token is the per-install token; agents get AgentTokenFor(Token, ns) as bearer token.
+	// Caller credentials are never forwarded.
+	Token string
 }
 
-func NewAgentProxy(c kubernetes.Interface) *AgentProxy {
-	return &AgentProxy{Client: c, HTTP: &http.Client{Timeout: 120 * time.Second}}
+func NewAgentProxy(c kubernetes.Interface, token string) *AgentProxy {
+	return &AgentProxy{Client: c, HTTP: &http.Client{Timeout: 120 * time.Second}, Token: token}
 }
 
 func (p *AgentProxy) Proxy(ctx context.Context, ns, svcName string, path string, w http.ResponseWriter, r *http.Request) error {
@@ -30,6 +33,11 @@ func (p *AgentProxy) Proxy(ctx context.Context, ns, svcName string, path string,
 		return err
 	}
 	req.Header = r.Header.Clone()
+	req.Header.Del("Authorization")
+	req.Header.Del("Cookie")
+	if p.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+AgentTokenFor(p.Token, ns))
+	}
 	resp, err := p.HTTP.Do(req)
 	if err != nil {
 		return err
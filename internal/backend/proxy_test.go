@@ -0,0 +1,39 @@
+package backend
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestProxySendsNamespaceToken(t *testing.T) {
+	var got *http.Request
+	p := NewAgentProxy(nil, "install")
+	p.HTTP.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		got = r
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("ok"))}, nil
+	})
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/tree?path=/x", nil)
+	req.Header.Set("Authorization", "Bearer caller")
+	req.Header.Set("Cookie", "session=1")
+	rr := httptest.NewRecorder()
+	if err := p.Proxy(context.Background(), "team", "agent", "/v1/tree", rr, req); err != nil {
+		t.Fatal(err)
+	}
+	if got.URL.Host != "agent.team.svc:8090" || got.URL.RawQuery != "path=/x" {
+		t.Errorf("proxied to %s", got.URL)
+	}
+	if a := got.Header.Get("Authorization"); a != "Bearer "+AgentTokenFor("install", "team") {
+		t.Errorf("Authorization %q, want the namespace token", a)
+	}
+	if got.Header.Get("Cookie") != "" {
+		t.Error("caller cookie forwarded")
+	}
+}
@@ -14,27 +14,39 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
 
-	"github.com/bmatcuk/doublestar/v4"
 	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
 	"go.uber.org/zap"
 )
 
 type Target struct {
-	Namespace    string
-	PVCName      string
-	StorageClass string
+	Namespace    string `json:"namespace"`
+	PVCName      string `json:"pvc"`
+	StorageClass string `json:"storageClass,omitempty"`
+	// Labels and Annotations of the PVC, for override selectors and security annotations
+	Labels      map[string]string `json:"-"`
+	Annotations map[string]string `json:"-"`
+	// Overrides are PVCViewerPolicy security overrides, tried before the cluster ones
+	Overrides []config.OverrideSpec `json:"-"`
 }
 
 type Reconciler struct {
 	Client     kubernetes.Interface
 	AgentImage string
-	Defaults   config.SecuritySpec
-	Overrides  []config.OverrideSpec
-	Disabled   atomic.Bool
-	Logger     *zap.SugaredLogger
+	// AgentToken authenticates the backend to agents; empty disables agent auth
+	AgentToken string
+	// Owner is set as ownerReference on all agent objects (see owner.go); nil disables
+	Owner    *metav1.OwnerReference
+	Disabled atomic.Bool
+	Logger   *zap.SugaredLogger
+	// Cache lists agent Pods from an informer; nil falls back to API calls
+	Cache *Cache
+
+	secrets agentSecrets
 }
 
-func (r *Reconciler) Reconcile(ctx context.Context, targets []Target) error {
+// Reconcile ensures per-PVC agents for targets under cfg, the config snapshot of this reconcile,
+// and removes all other per-PVC agents.
+func (r *Reconciler) Reconcile(ctx context.Context, cfg *config.Config, targets []Target) error {
 	if r.Disabled.Load() {
 		return nil
 	}
@@ -43,14 +55,13 @@ func (r *Reconciler) Reconcile(ctx context.Context, targets []Target) error {
 		desired[key(t)] = t
 	}
 	// List existing agent Pods by label
-	sel := labels.SelectorFromSet(labels.Set{"app": "pvc-viewer-agent"})
-	pods, err := r.Client.CoreV1().Pods("").List(ctx, metav1.ListOptions{LabelSelector: sel.String()})
+	pods, err := r.agentPods(ctx, "pvc-viewer-agent", "")
 	if err != nil {
 		return err
 	}
 
 	existing := map[string]struct{}{}
-	for _, p := range pods.Items {
+	for _, p := range pods {
 		ns := p.Labels["pvcviewer.k8s.io/ns"]
 		pvc := p.Labels["pvcviewer.k8s.io/pvc"]
 		if ns != "" && pvc != "" {
@@ -60,12 +71,13 @@ func (r *Reconciler) Reconcile(ctx context.Context, targets []Target) error {
 
 	// Ensure desired
 	for _, t := range targets {
-		if err := r.ensureAgent(ctx, t); err != nil {
+		if err := r.ensureAgent(ctx, cfg, t); err != nil {
 			return err
 		}
 	}
 
 	// GC
+	touched := map[string]struct{}{}
 	for k := range existing {
 		if _, ok := desired[k]; !ok {
 			// parse key
@@ -75,12 +87,103 @@ func (r *Reconciler) Reconcile(ctx context.Context, targets []Target) error {
 			}
 			_ = r.Client.CoreV1().Pods(parts[0]).Delete(ctx, AgentName(parts[0], parts[1]), metav1.DeleteOptions{})
 			_ = r.Client.CoreV1().Services(parts[0]).Delete(ctx, AgentName(parts[0], parts[1]), metav1.DeleteOptions{})
+			touched[parts[0]] = struct{}{}
 		}
 	}
+	for ns := range touched {
+		r.gcAgentSecret(ctx, ns)
+	}
 	return nil
 }
 
-func (r *Reconciler) ensureAgent(ctx context.Context, t Target) error {
+// ReconcileNamespace ensures per-PVC agents for targets in ns and removes the other per-PVC agents there.
+func (r *Reconciler) ReconcileNamespace(ctx context.Context, cfg *config.Config, ns string, targets []Target) error {
+	if r.Disabled.Load() {
+		return nil
+	}
+	desired := map[string]struct{}{}
+	for _, t := range targets {
+		desired[t.PVCName] = struct{}{}
+		if err := r.ensureAgent(ctx, cfg, t); err != nil {
+			return err
+		}
+	}
+	pods, err := r.agentPods(ctx, "pvc-viewer-agent", ns)
+	if err != nil {
+		return err
+	}
+	removed := false
+	for _, p := range pods {
+		if _, ok := desired[p.Labels["pvcviewer.k8s.io/pvc"]]; !ok {
+			_ = r.Client.CoreV1().Pods(ns).Delete(ctx, p.Name, metav1.DeleteOptions{})
+			_ = r.Client.CoreV1().Services(ns).Delete(ctx, p.Name, metav1.DeleteOptions{})
+			removed = true
+		}
+	}
+	if removed {
+		r.gcAgentSecret(ctx, ns)
+	}
+	return nil
+}
+
+// EnsureTarget ensures the per-PVC agent for t.
+func (r *Reconciler) EnsureTarget(ctx context.Context, cfg *config.Config, t Target) error {
+	return r.ensureAgent(ctx, cfg, t)
+}
+
+// RemoveAgent deletes the per-PVC agent Pod and Service for ns/pvc, if any.
+func (r *Reconciler) RemoveAgent(ctx context.Context, ns, pvc string) {
+	if r.Disabled.Load() {
+		return
+	}
+	name := AgentName(ns, pvc)
+	if r.Cache != nil {
+		if _, err := r.Cache.AgentPods.Pods(ns).Get(name); err != nil {
+			return
+		}
+	}
+	_ = r.Client.CoreV1().Pods(ns).Delete(ctx, name, metav1.DeleteOptions{})
+	_ = r.Client.CoreV1().Services(ns).Delete(ctx, name, metav1.DeleteOptions{})
+	r.gcAgentSecret(ctx, ns)
+}
+
+// agentPods lists agent Pods with the given app label in ns ("" for all namespaces).
+func (r *Reconciler) agentPods(ctx context.Context, app, ns string) ([]*corev1.Pod, error) {
+	sel := labels.SelectorFromSet(labels.Set{"app": app})
+	if r.Cache != nil {
+		if ns == "" {
+			return r.Cache.AgentPods.List(sel)
+		}
+		return r.Cache.AgentPods.Pods(ns).List(sel)
+	}
+	pods, err := r.Client.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{LabelSelector: sel.String()})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*corev1.Pod, 0, len(pods.Items))
+	for i := range pods.Items {
+		out = append(out, &pods.Items[i])
+	}
+	return out, nil
+}
+
+// agentPod returns the agent Pod ns/name, from the cache when there is one.
+func (r *Reconciler) agentPod(ctx context.Context, ns, name string) (*corev1.Pod, error) {
+	if r.Cache != nil {
+		return r.Cache.AgentPods.Pods(ns).Get(name)
+	}
+	return r.Client.CoreV1().Pods(ns).Get(ctx, name, metav1.GetOptions{})
+}
+
+// agentService returns the agent Service ns/name, from the cache when there is one.
+func (r *Reconciler) agentService(ctx context.Context, ns, name string) (*corev1.Service, error) {
+	if r.Cache != nil {
+		return r.Cache.AgentServices.Services(ns).Get(name)
+	}
+	return r.Client.CoreV1().Services(ns).Get(ctx, name, metav1.GetOptions{})
+}
+
+func (r *Reconciler) ensureAgent(ctx context.Context, cfg *config.Config, t Target) error {
 	if r.Disabled.Load() {
 		return nil
 	}
@@ -91,7 +194,6 @@ func (r *Reconciler) ensureAgent(ctx context.Context, t Target) error {
 		"pvcviewer.k8s.io/pvc": t.PVCName,
 	}
 	// Ensure Service (ClusterIP)
-	_, _ = r.Client.CoreV1().Services(t.Namespace).Get(ctx, name, metav1.GetOptions{})
 	svc := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: t.Namespace, Labels: labels},
 		Spec: corev1.ServiceSpec{
@@ -101,14 +203,16 @@ func (r *Reconciler) ensureAgent(ctx context.Context, t Target) error {
 			}},
 		},
 	}
-	if _, err := r.Client.CoreV1().Services(t.Namespace).Create(ctx, svc, metav1.CreateOptions{}); err == nil {
+	if created, err := r.ensureService(ctx, svc); err != nil {
 		if r.Logger != nil {
-			r.Logger.Infow("agent service ensured", "ns", t.Namespace, "svc", name)
+			r.Logger.Warnw("agent service ensure failed", "ns", t.Namespace, "svc", name, "error", err)
 		}
+	} else if created && r.Logger != nil {
+		r.Logger.Infow("agent service ensured", "ns", t.Namespace, "svc", name)
 	}
 
-	// Resolve security for this storageClass
-	sec := r.resolveSecurityForStorageClass(t.PVCName, t.StorageClass)
+	// Resolve security and Pod template from defaults, overrides and the PVC's own annotations
+	sec, tpl := r.resolveProfile(cfg, t)
 
 	ro := sec.ReadOnly
 	// Compute desired spec hash to detect changes (image/security/readOnly)
@@ -123,7 +227,7 @@ func (r *Reconciler) ensureAgent(ctx context.Context, t Target) error {
 		fg = *sec.FSGroup
 	}
 	// sort supplemental for stability
-	supp := append([]int64{}, mergeSupplemental(r.Defaults.SupplementalGroups, sec.SupplementalGroups)...)
+	supp := append([]int64{}, mergeSupplemental(cfg.Agents.SecurityDefaults.SupplementalGroups, sec.SupplementalGroups)...)
 	// stringify supp
 	suppStr := ""
 	for i, g := range supp {
@@ -132,24 +236,28 @@ func (r *Reconciler) ensureAgent(ctx context.Context, t Target) error {
 		}
 		suppStr += fmt.Sprintf("%d", g)
 	}
-	sh := sha1.Sum([]byte(fmt.Sprintf("img=%s|ru=%d|rg=%d|fg=%d|ro=%t|supp=%s", r.AgentImage, ru, rg, fg, ro, suppStr)))
+	sh := sha1.Sum([]byte(fmt.Sprintf("img=%s|ru=%d|rg=%d|fg=%d|ro=%t|supp=%s|tok=%s|owner=%s", r.AgentImage, ru, rg, fg, ro, suppStr, r.agentTokenHash(), r.ownerUID()) + templateHashPart(tpl)))
 	desiredHash := hex.EncodeToString(sh[:8])
 
+	if err := r.ensureAgentSecret(ctx, t.Namespace); err != nil {
+		return err
+	}
+
 	// If pod exists with different hash -> recreate
-	if existing, err := r.Client.CoreV1().Pods(t.Namespace).Get(ctx, name, metav1.GetOptions{}); err == nil {
+	if existing, err := r.agentPod(ctx, t.Namespace, name); err == nil {
 		if existing.Annotations != nil && existing.Annotations["pvcviewer.k8s.io/spec-hash"] == desiredHash {
 			return nil
 		}
 		_ = r.Client.CoreV1().Pods(t.Namespace).Delete(ctx, name, metav1.DeleteOptions{})
 	}
 	pod := &corev1.Pod{
-		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: t.Namespace, Labels: labels, Annotations: map[string]string{"pvcviewer.k8s.io/spec-hash": desiredHash}},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: t.Namespace, Labels: labels, Annotations: map[string]string{"pvcviewer.k8s.io/spec-hash": desiredHash}, OwnerReferences: r.ownerRefs()},
 		Spec: corev1.PodSpec{
 			Containers: []corev1.Container{{
 				Name:           "agent",
 				Image:          r.AgentImage,
 				Command:        []string{"/bin/agent"},
-				Env:            []corev1.EnvVar{{Name: "PVC_VIEWER_DATA_ROOT", Value: "/data"}, {Name: "PVC_VIEWER_READ_ONLY", Value: boolString(ro)}},
+				Env:            r.agentEnv(ro),
 				Ports:          []corev1.ContainerPort{{ContainerPort: 8090}},
 				VolumeMounts:   []corev1.VolumeMount{{Name: "data", MountPath: "/data", ReadOnly: ro}},
 				ReadinessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(8090)}}, PeriodSeconds: 2, FailureThreshold: 3},
@@ -170,10 +278,11 @@ func (r *Reconciler) ensureAgent(ctx context.Context, t Target) error {
 				RunAsUser:          pickInt(sec.RunAsUser, 65532),
 				RunAsGroup:         pickInt(sec.RunAsGroup, 65532),
 				FSGroup:            sec.FSGroup,
-				SupplementalGroups: mergeSupplemental(r.Defaults.SupplementalGroups, sec.SupplementalGroups),
+				SupplementalGroups: mergeSupplemental(cfg.Agents.SecurityDefaults.SupplementalGroups, sec.SupplementalGroups),
 			},
 		},
 	}
+	applyPodTemplate(pod, tpl)
 	if _, err := r.Client.CoreV1().Pods(t.Namespace).Create(ctx, pod, metav1.CreateOptions{}); err == nil {
 		if r.Logger != nil {
 			r.Logger.Infow("agent pod ensured", "ns", t.Namespace, "pod", name, "pvc", t.PVCName)
@@ -200,49 +309,16 @@ func boolString(b bool) string {
 	return "false"
 }
 
-// resolveSecurityForStorageClass merges defaults with first matching override
-func (r *Reconciler) resolveSecurityForStorageClass(pvcName, sc string) config.SecuritySpec {
-	out := r.Defaults
-	for _, o := range r.Overrides {
-		// pvc-specific override takes precedence
-		if o.PvcMatch != "" {
-			if ok, _ := doublestar.Match(o.PvcMatch, pvcName); ok {
-				if o.RunAsUser != nil {
-					out.RunAsUser = o.RunAsUser
-				}
-				if o.RunAsGroup != nil {
-					out.RunAsGroup = o.RunAsGroup
-				}
-				if o.FSGroup != nil {
-					out.FSGroup = o.FSGroup
-				}
-				if len(o.SupplementalGroups) > 0 {
-					out.SupplementalGroups = o.SupplementalGroups
-				}
-				out.ReadOnly = out.ReadOnly || o.ReadOnly
-				break
-			}
-			continue
-		}
-		// simple exact match on storageClass
-		if o.Match == sc {
-			if o.RunAsUser != nil {
-				out.RunAsUser = o.RunAsUser
-			}
-			if o.RunAsGroup != nil {
-				out.RunAsGroup = o.RunAsGroup
-			}
-			if o.FSGroup != nil {
-				out.FSGroup = o.FSGroup
-			}
-			if len(o.SupplementalGroups) > 0 {
-				out.SupplementalGroups = o.SupplementalGroups
-			}
-			out.ReadOnly = out.ReadOnly || o.ReadOnly
-			break
-		}
+// resolveProfile resolves t with cfg's defaults and overrides (see ResolveSecurity), applies t's
+// security annotations and merges the Pod template.
+func (r *Reconciler) resolveProfile(cfg *config.Config, t Target) (config.SecuritySpec, config.PodTemplate) {
+	overrides := withClusterOverrides(t, cfg.Agents.SecurityOverrides)
+	sec, matched := ResolveSecurity(cfg.Agents.SecurityDefaults, overrides, t)
+	sec, rejected := ApplyAnnotations(sec, cfg.Agents.Annotations, t.Annotations)
+	if len(rejected) > 0 && r.Logger != nil {
+		r.Logger.Warnw("ignoring PVC security annotations", "ns", t.Namespace, "pvc", t.PVCName, "reasons", rejected)
 	}
-	return out
+	return sec, ResolvePodTemplate(cfg.Agents.PodTemplate, overrides, matched)
 }
 
 func pickInt(v *int64, def int64) *int64 {
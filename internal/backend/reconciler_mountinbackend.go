@@ -1,5 +1,113 @@
 package backend
 
-// Placeholder for mode "mount-in-backend": it requires backend Pod to mount multiple PVCs.
-// This mode is wired via Helm values (mode.dataPlane) and would be implemented by rendering
-// volumes/volumeMounts into the backend Deployment, then triggering rollout on config changes.
+import (
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/agent"
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
+)
+
+// ModeMountInBackend is the data-plane mode in which PVCs are mounted directly into the backend Pod.
+// Volumes/volumeMounts are rendered by Helm from mountPVCs, so adding or removing a PVC requires
+// a backend rollout (checksum/config). File APIs are then served in-process by agent handlers.
+const ModeMountInBackend = "mount-in-backend"
+
+// MountDataPlane maps ns/pvc pairs to agent handlers rooted at the backend's mount paths.
+type MountDataPlane struct {
+	// Namespace is the backend's own namespace; mounted PVCs always live there.
+	Namespace string
+	Logger    *zap.SugaredLogger
+
+	mu      sync.RWMutex
+	servers map[string]*agent.HTTPServer
+}
+
+func NewMountDataPlane(namespace string, logger *zap.SugaredLogger) *MountDataPlane {
+	return &MountDataPlane{Namespace: namespace, Logger: logger, servers: map[string]*agent.HTTPServer{}}
+}
+
+// Apply rebuilds handlers from cfg.MountPVCs. Entries whose mount path is not present in the
+// Pod (not yet rolled out) are skipped with a warning. Handlers of unchanged mounts are kept, so
+// their running copy jobs stay reachable.
+func (m *MountDataPlane) Apply(cfg *config.Config) {
+	m.mu.RLock()
+	prev := m.servers
+	m.mu.RUnlock()
+	servers := map[string]*agent.HTTPServer{}
+	for _, mp := range cfg.MountPVCs {
+		if mp.PvcName == "" || mp.MountPath == "" {
+			m.Logger.Warnw("mountPVCs entry requires pvcName and mountPath", "pvc", mp.PvcName, "mountPath", mp.MountPath)
+			continue
+		}
+		fi, err := os.Stat(mp.MountPath)
+		if err != nil || !fi.IsDir() {
+			m.Logger.Warnw("mount path not available; backend restart required", "pvc", mp.PvcName, "mountPath", mp.MountPath, "error", err)
+			continue
+		}
+		ro := mp.ReadOnly || cfg.Agents.SecurityDefaults.ReadOnly
+		k := key(Target{Namespace: m.Namespace, PVCName: mp.PvcName})
+		if srv, ok := prev[k]; ok && srv.DataRoot == mp.MountPath && srv.ReadOnly == ro {
+			servers[k] = srv
+			continue
+		}
+		srv := agent.NewHTTPServer(mp.MountPath, ro)
+		srv.Logger = m.Logger.With("ns", m.Namespace, "pvc", mp.PvcName)
+		servers[k] = srv
+		m.Logger.Infow("mounted pvc served in backend", "ns", m.Namespace, "pvc", mp.PvcName, "mountPath", mp.MountPath, "readOnly", ro)
+	}
+	m.mu.Lock()
+	m.servers = servers
+	m.mu.Unlock()
+}
+
+// Lookup returns the in-process handler for ns/pvc if it is mounted.
+func (m *MountDataPlane) Lookup(ns, pvc string) (*agent.HTTPServer, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	srv, ok := m.servers[key(Target{Namespace: ns, PVCName: pvc})]
+	return srv, ok
+}
+
+// Serve dispatches r to the agent route agentPath of the mounted PVC.
+// Returns false if ns/pvc is not mounted.
+func (m *MountDataPlane) Serve(ns, pvc, agentPath string, w http.ResponseWriter, r *http.Request) bool {
+	srv, ok := m.Lookup(ns, pvc)
+	if !ok {
+		return false
+	}
+	rc := r.Clone(r.Context())
+	rc.URL.Path = agentPath
+	rc.URL.RawPath = ""
+	srv.Router.ServeHTTP(w, rc)
+	return true
+}
+
+// Targets returns mounted PVCs, optionally restricted to a namespace (empty = all).
+func (m *MountDataPlane) Targets(ns string) []Target {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]Target, 0, len(m.servers))
+	for k := range m.servers {
+		t := targetFromKey(k)
+		if ns != "" && t.Namespace != ns {
+			continue
+		}
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return key(out[i]) < key(out[j]) })
+	return out
+}
+
+func targetFromKey(k string) Target {
+	parts := strings.SplitN(k, "/", 2)
+	if len(parts) != 2 {
+		return Target{PVCName: k}
+	}
+	return Target{Namespace: parts[0], PVCName: parts[1]}
+}
@@ -0,0 +1,34 @@
+package backend
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
+)
+
+func TestMountDataPlaneApplyKeepsUnchangedServers(t *testing.T) {
+	dirA, dirB := t.TempDir(), t.TempDir()
+	m := NewMountDataPlane("ns", zap.NewNop().Sugar())
+	cfg := &config.Config{MountPVCs: []config.MountPVC{{PvcName: "a", MountPath: dirA}, {PvcName: "b", MountPath: dirB}}}
+	m.Apply(cfg)
+	a1, _ := m.Lookup("ns", "a")
+	b1, _ := m.Lookup("ns", "b")
+
+	cfg2 := &config.Config{MountPVCs: []config.MountPVC{{PvcName: "a", MountPath: dirA}, {PvcName: "b", MountPath: dirB, ReadOnly: true}}}
+	m.Apply(cfg2)
+	a2, _ := m.Lookup("ns", "a")
+	b2, _ := m.Lookup("ns", "b")
+	if a1 != a2 {
+		t.Error("unchanged mount got a new server; its copy jobs would be lost")
+	}
+	if b1 == b2 || !b2.ReadOnly {
+		t.Error("changed mount kept its old server")
+	}
+
+	m.Apply(&config.Config{})
+	if _, ok := m.Lookup("ns", "a"); ok {
+		t.Error("removed mount still served")
+	}
+}
@@ -11,61 +11,47 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
-	"github.com/bmatcuk/doublestar/v4"
 	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
 )
 
-// EnsureNamespaceAgent groups PVCs by effective security profile and ensures one agent Pod/Service per group.
-func (r *Reconciler) EnsureNamespaceAgent(ctx context.Context, namespace string, pvcNames []string) error {
-	if len(pvcNames) == 0 {
+// EnsureNamespaceAgent groups the namespace's targets by effective security profile and ensures one
+// agent Pod/Service per group.
+func (r *Reconciler) EnsureNamespaceAgent(ctx context.Context, cfg *config.Config, namespace string, targets []Target) error {
+	if len(targets) == 0 {
 		return nil
 	}
 
 	// Best-effort cleanup of legacy single-agent resources
 	legacy := NamespaceAgentName(namespace)
-	_ = r.Client.CoreV1().Pods(namespace).Delete(ctx, legacy, metav1.DeleteOptions{})
-	_ = r.Client.CoreV1().Services(namespace).Delete(ctx, legacy, metav1.DeleteOptions{})
+	if _, err := r.agentPod(ctx, namespace, legacy); err == nil {
+		_ = r.Client.CoreV1().Pods(namespace).Delete(ctx, legacy, metav1.DeleteOptions{})
+	}
+	if _, err := r.agentService(ctx, namespace, legacy); err == nil {
+		_ = r.Client.CoreV1().Services(namespace).Delete(ctx, legacy, metav1.DeleteOptions{})
+	}
 
 	type group struct {
 		pvcs []string
 		sec  config.SecuritySpec
+		tpl  config.PodTemplate
 	}
 	groups := map[string]*group{}
 
 	// Build groups by security profile
-	for _, pvc := range pvcNames {
-		sc := ""
-		if p, err := r.Client.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, pvc, metav1.GetOptions{}); err == nil {
-			if p.Spec.StorageClassName != nil {
-				sc = *p.Spec.StorageClassName
-			} else if p.Spec.VolumeName != "" {
-				if pv, err2 := r.Client.CoreV1().PersistentVolumes().Get(ctx, p.Spec.VolumeName, metav1.GetOptions{}); err2 == nil {
-					sc = pv.Spec.StorageClassName
-				}
-			}
-		}
-		// compute effective security from defaults + first matching override (glob)
-		eff := r.Defaults
-		for _, ov := range r.Overrides {
-			if ov.PvcMatch != "" {
-				if ok, _ := doublestar.Match(ov.PvcMatch, pvc); ok {
-					eff = mergeSecurity(eff, ov.SecuritySpec)
-					break
-				}
-				continue
-			}
-			if ok, _ := doublestar.Match(ov.Match, sc); ok {
-				eff = mergeSecurity(eff, ov.SecuritySpec)
-				break
-			}
-		}
-		key := ProfileKey(eff)
+	for _, t := range targets {
+		pvc := t.PVCName
+		eff, tpl := r.resolveProfile(cfg, t)
+		key := GroupKey(eff, tpl)
 		if _, ok := groups[key]; !ok {
-			groups[key] = &group{pvcs: []string{}, sec: eff}
+			groups[key] = &group{pvcs: []string{}, sec: eff, tpl: tpl}
 		}
 		groups[key].pvcs = append(groups[key].pvcs, pvc)
 	}
 
+	if err := r.ensureAgentSecret(ctx, namespace); err != nil {
+		return err
+	}
+
 	desired := map[string]struct{}{}
 	// Ensure each group
 	for key, g := range groups {
@@ -102,7 +88,7 @@ func (r *Reconciler) EnsureNamespaceAgent(ctx context.Context, namespace string,
 		if g.sec.FSGroup != nil {
 			fg = *g.sec.FSGroup
 		}
-		specStr := base + fmt.Sprintf("|img=%s|ru=%d|rg=%d|fg=%d|ro=%t|supp=%v", r.AgentImage, ru, rg, fg, g.sec.ReadOnly, mergeSupplemental(r.Defaults.SupplementalGroups, g.sec.SupplementalGroups))
+		specStr := base + fmt.Sprintf("|img=%s|ru=%d|rg=%d|fg=%d|ro=%t|supp=%v|tok=%s|owner=%s|pvcdirs", r.AgentImage, ru, rg, fg, g.sec.ReadOnly, mergeSupplemental(cfg.Agents.SecurityDefaults.SupplementalGroups, g.sec.SupplementalGroups), r.agentTokenHash(), r.ownerUID()) + templateHashPart(g.tpl)
 		h := sha1.Sum([]byte(specStr))
 		desiredHash := hex.EncodeToString(h[:8])
 
@@ -111,13 +97,13 @@ func (r *Reconciler) EnsureNamespaceAgent(ctx context.Context, namespace string,
 			Selector: labels,
 			Ports:    []corev1.ServicePort{{Name: "http", Port: 8090, TargetPort: intstr.FromInt(8090)}},
 		}}
-		if _, err := r.Client.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{}); err != nil {
-			_, _ = r.Client.CoreV1().Services(namespace).Create(ctx, svc, metav1.CreateOptions{})
+		if _, err := r.ensureService(ctx, svc); err != nil && r.Logger != nil {
+			r.Logger.Warnw("ns agent service ensure failed", "namespace", namespace, "svc", name, "error", err)
 		}
 
 		// Pod
 		recreate := true
-		if existing, err := r.Client.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{}); err == nil {
+		if existing, err := r.agentPod(ctx, namespace, name); err == nil {
 			if existing.Annotations != nil && existing.Annotations["pvcviewer.k8s.io/spec-hash"] == desiredHash {
 				recreate = false
 			} else {
@@ -132,16 +118,16 @@ func (r *Reconciler) EnsureNamespaceAgent(ctx context.Context, namespace string,
 				sec.RunAsGroup = sec.FSGroup
 			}
 			// Always include FSGroup into supplemental groups as well
-			sup := mergeSupplemental(r.Defaults.SupplementalGroups, sec.SupplementalGroups)
+			sup := mergeSupplemental(cfg.Agents.SecurityDefaults.SupplementalGroups, sec.SupplementalGroups)
 			if sec.FSGroup != nil {
 				sup = mergeSupplemental(sup, []int64{*sec.FSGroup})
 			}
-			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels, Annotations: ann}, Spec: corev1.PodSpec{
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels, Annotations: ann, OwnerReferences: r.ownerRefs()}, Spec: corev1.PodSpec{
 				Containers: []corev1.Container{{
 					Name:           "agent",
 					Image:          r.AgentImage,
 					Command:        []string{"/bin/agent"},
-					Env:            []corev1.EnvVar{{Name: "PVC_VIEWER_DATA_ROOT", Value: "/data"}, {Name: "PVC_VIEWER_READ_ONLY", Value: boolString(sec.ReadOnly)}},
+					Env:            append(r.agentEnv(sec.ReadOnly), corev1.EnvVar{Name: "PVC_VIEWER_PVC_DIRS", Value: "true"}),
 					Ports:          []corev1.ContainerPort{{ContainerPort: 8090}},
 					VolumeMounts:   mounts,
 					ReadinessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(8090)}}, PeriodSeconds: 2, FailureThreshold: 3},
@@ -167,6 +153,7 @@ func (r *Reconciler) EnsureNamespaceAgent(ctx context.Context, namespace string,
 				mode := corev1.PodFSGroupChangePolicy("OnRootMismatch")
 				pod.Spec.SecurityContext.FSGroupChangePolicy = &mode
 			}
+			applyPodTemplate(pod, g.tpl)
 			if created, err := r.Client.CoreV1().Pods(namespace).Create(ctx, pod, metav1.CreateOptions{}); err == nil {
 				if r.Logger != nil {
 					r.Logger.Infow("ns agent group ensured", "namespace", namespace, "name", created.Name, "pvcs", g.pvcs, "fsGroup", valueOrNil(sec.FSGroup), "runAsUser", valueOrNil(sec.RunAsUser), "runAsGroup", valueOrNil(sec.RunAsGroup), "readOnly", sec.ReadOnly)
@@ -176,8 +163,8 @@ func (r *Reconciler) EnsureNamespaceAgent(ctx context.Context, namespace string,
 	}
 
 	// GC pods/services not in desired
-	podList, _ := r.Client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: "app=pvc-viewer-agent-ns"})
-	for _, p := range podList.Items {
+	podList, _ := r.agentPods(ctx, "pvc-viewer-agent-ns", namespace)
+	for _, p := range podList {
 		if _, ok := desired[p.Name]; !ok {
 			_ = r.Client.CoreV1().Pods(namespace).Delete(ctx, p.Name, metav1.DeleteOptions{})
 			_ = r.Client.CoreV1().Services(namespace).Delete(ctx, p.Name, metav1.DeleteOptions{})
@@ -197,29 +184,52 @@ func stringsJoin(in []string, sep string) string {
 	return out
 }
 
+// RemoveNamespaceAgents deletes all namespace agents in ns, e.g. after its last PVC stopped matching.
+func (r *Reconciler) RemoveNamespaceAgents(ctx context.Context, ns string) error {
+	if r.Disabled.Load() {
+		return nil
+	}
+	pods, err := r.agentPods(ctx, "pvc-viewer-agent-ns", ns)
+	if err != nil || len(pods) == 0 {
+		return err
+	}
+	for _, p := range pods {
+		_ = r.Client.CoreV1().Pods(ns).Delete(ctx, p.Name, metav1.DeleteOptions{})
+		_ = r.Client.CoreV1().Services(ns).Delete(ctx, p.Name, metav1.DeleteOptions{})
+	}
+	r.gcAgentSecret(ctx, ns)
+	return nil
+}
+
 // GCPerPVCAll deletes all per-PVC agents and their services
 func (r *Reconciler) GCPerPVCAll(ctx context.Context) error {
 	// pods with app=pvc-viewer-agent
-	pods, err := r.Client.CoreV1().Pods("").List(ctx, metav1.ListOptions{LabelSelector: "app=pvc-viewer-agent"})
+	pods, err := r.agentPods(ctx, "pvc-viewer-agent", "")
 	if err != nil {
 		return err
 	}
-	for _, p := range pods.Items {
+	touched := map[string]struct{}{}
+	for _, p := range pods {
 		ns := p.Namespace
 		name := p.Name
 		_ = r.Client.CoreV1().Pods(ns).Delete(ctx, name, metav1.DeleteOptions{})
 		_ = r.Client.CoreV1().Services(ns).Delete(ctx, name, metav1.DeleteOptions{})
+		touched[ns] = struct{}{}
+	}
+	for ns := range touched {
+		r.gcAgentSecret(ctx, ns)
 	}
 	return nil
 }
 
 // GCNamespaceAgents deletes namespace agents not in keep set
 func (r *Reconciler) GCNamespaceAgents(ctx context.Context, keepNamespaces map[string]struct{}) error {
-	pods, err := r.Client.CoreV1().Pods("").List(ctx, metav1.ListOptions{LabelSelector: "app=pvc-viewer-agent-ns"})
+	pods, err := r.agentPods(ctx, "pvc-viewer-agent-ns", "")
 	if err != nil {
 		return err
 	}
-	for _, p := range pods.Items {
+	touched := map[string]struct{}{}
+	for _, p := range pods {
 		if _, ok := keepNamespaces[p.Namespace]; ok {
 			continue
 		}
@@ -227,6 +237,10 @@ func (r *Reconciler) GCNamespaceAgents(ctx context.Context, keepNamespaces map[s
 		name := p.Name
 		_ = r.Client.CoreV1().Pods(ns).Delete(ctx, name, metav1.DeleteOptions{})
 		_ = r.Client.CoreV1().Services(ns).Delete(ctx, name, metav1.DeleteOptions{})
+		touched[ns] = struct{}{}
+	}
+	for ns := range touched {
+		r.gcAgentSecret(ctx, ns)
 	}
 	return nil
 }
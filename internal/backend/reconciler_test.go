@@ -0,0 +1,127 @@
+package backend
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
+)
+
+func agentsConfig(uid int64) *config.Config {
+	c := &config.Config{}
+	c.Mode.DataPlane = "agent-per-pvc"
+	c.Agents.SecurityDefaults = config.SecuritySpec{RunAsUser: &uid}
+	c.Agents.PodTemplate = config.PodTemplate{Labels: map[string]string{"uid": strconv.FormatInt(uid, 10)}}
+	return c
+}
+
+// TestReconcileDuringReload reconciles while the config is reloaded; run with -race.
+func TestReconcileDuringReload(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	state := config.NewState()
+	state.ApplyNewConfig(agentsConfig(1000))
+	r := &Reconciler{Client: client, AgentImage: "agent"}
+	targets := []Target{{Namespace: "a", PVCName: "data"}, {Namespace: "b", PVCName: "logs"}}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := int64(0); i < 50; i++ {
+			state.ApplyNewConfig(agentsConfig(1000 + i))
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		if err := r.Reconcile(context.Background(), state.Current(), targets); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.EnsureNamespaceAgent(context.Background(), state.Current(), "a", targets[:1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	// the agent follows the config the last reconcile saw
+	final := agentsConfig(4242)
+	if err := r.Reconcile(context.Background(), final, targets); err != nil {
+		t.Fatal(err)
+	}
+	pod, err := client.CoreV1().Pods("a").Get(context.Background(), AgentName("a", "data"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := *pod.Spec.SecurityContext.RunAsUser; got != 4242 {
+		t.Errorf("runAsUser %d, want 4242 from the reconciled config", got)
+	}
+	if pod.Labels["uid"] != "4242" {
+		t.Errorf("pod template labels %v not from the reconciled config", pod.Labels)
+	}
+}
+
+// agentActions returns the verbs the reconciler sent for agent Pods and Services.
+func agentActions(client *fake.Clientset) []string {
+	var out []string
+	for _, a := range client.Actions() {
+		if r := a.GetResource().Resource; r == "pods" || r == "services" {
+			if a.GetVerb() != "list" && a.GetVerb() != "watch" {
+				out = append(out, a.GetVerb()+" "+r)
+			}
+		}
+	}
+	return out
+}
+
+func TestReconcileReadsAgentsFromCache(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := fake.NewSimpleClientset()
+	c := NewCache(client, 0)
+	if err := c.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	r := &Reconciler{Client: client, AgentImage: "agent", Cache: c}
+	cfg := agentsConfig(1000)
+	pvc := Target{Namespace: "a", PVCName: "data"}
+	ns := Target{Namespace: "b", PVCName: "logs"}
+
+	ensure := func() {
+		t.Helper()
+		if err := r.EnsureTarget(ctx, cfg, pvc); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.EnsureNamespaceAgent(ctx, cfg, "b", []Target{ns}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ensure()
+	if got, want := agentActions(client), []string{"create services", "create pods", "create services", "create pods"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("first sync sent %v, want %v", got, want)
+	}
+
+	// once the informers caught up, an unchanged agent costs no API calls
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		pods, _ := c.AgentPods.List(labels.Everything())
+		svcs, _ := c.AgentServices.List(labels.Everything())
+		if len(pods) == 2 && len(svcs) == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("cache has %d pods and %d services, want 2 each", len(pods), len(svcs))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	client.ClearActions()
+	ensure()
+	if got := agentActions(client); len(got) != 0 {
+		t.Errorf("second sync sent %v, want nothing", got)
+	}
+}
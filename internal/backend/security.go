@@ -3,61 +3,198 @@ package backend
 import (
 	"crypto/sha1"
 	"encoding/hex"
+	"fmt"
 	"sort"
 	"strconv"
 
-	corev1 "k8s.io/api/core/v1"
-
 	"github.com/bmatcuk/doublestar/v4"
+	"k8s.io/apimachinery/pkg/labels"
 
 	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
 )
 
-// buildPodSecurityContext builds Pod-level security context using defaults and any matching overrides by storage class.
-func buildPodSecurityContext(r *Reconciler, t Target) *corev1.PodSecurityContext {
-	spec := r.Defaults
-	// apply first matching override by StorageClass
-	for _, ov := range r.Overrides {
-		if ok, _ := doublestar.Match(ov.Match, t.StorageClass); ok {
-			spec = mergeSecurity(spec, ov.SecuritySpec)
-			break
+// ResolveSecurity is the single override resolver used by both agent modes, routing, plan and
+// explain. An override matches t when all of its set conditions hold: namespace, pvcMatch and
+// match (storage class) globs and the PVC label selector. All matching overrides are merged over
+// defaults field by field; for a field set by several, the highest priority wins and, on equal
+// priority, the one listed first. readOnly from any match makes the profile read-only.
+// overrides is t.Overrides (PVCViewerPolicy) followed by the cluster list (see withClusterOverrides).
+// It returns the indices of the matching overrides in precedence order.
+func ResolveSecurity(defaults config.SecuritySpec, overrides []config.OverrideSpec, t Target) (config.SecuritySpec, []int) {
+	matched := []int{}
+	for i, o := range overrides {
+		if overrideMatches(o, t) {
+			matched = append(matched, i)
 		}
 	}
+	sort.SliceStable(matched, func(a, b int) bool { return overrides[matched[a]].Priority > overrides[matched[b]].Priority })
+	out := defaults
+	// apply lowest precedence first so higher precedence overwrites
+	for i := len(matched) - 1; i >= 0; i-- {
+		out = mergeSecurity(out, overrides[matched[i]].SecuritySpec)
+	}
+	return out, matched
+}
+
+// withClusterOverrides lists t's policy overrides before the cluster overrides.
+func withClusterOverrides(t Target, cluster []config.OverrideSpec) []config.OverrideSpec {
+	return append(append([]config.OverrideSpec{}, t.Overrides...), cluster...)
+}
 
-	psc := &corev1.PodSecurityContext{}
-	if spec.RunAsUser != nil {
-		psc.RunAsUser = spec.RunAsUser
+// overrideMatches reports whether every condition set on o holds for t; an override without
+// conditions matches nothing.
+func overrideMatches(o config.OverrideSpec, t Target) bool {
+	if o.Namespace == "" && o.PvcMatch == "" && o.Match == "" && o.Selector == "" {
+		return false
 	}
-	if spec.RunAsGroup != nil {
-		psc.RunAsGroup = spec.RunAsGroup
+	for _, c := range []struct{ pattern, s string }{{o.Namespace, t.Namespace}, {o.PvcMatch, t.PVCName}, {o.Match, t.StorageClass}} {
+		if c.pattern == "" {
+			continue
+		}
+		if ok, _ := doublestar.Match(c.pattern, c.s); !ok {
+			return false
+		}
 	}
-	if spec.FSGroup != nil {
-		psc.FSGroup = spec.FSGroup
+	if o.Selector != "" {
+		sel, err := labels.Parse(o.Selector)
+		if err != nil || !sel.Matches(labels.Set(t.Labels)) {
+			return false
+		}
 	}
-	if len(spec.SupplementalGroups) > 0 {
-		psc.SupplementalGroups = spec.SupplementalGroups
+	return true
+}
+
+// PVC annotations adjusting the agent's security profile, bounded by agents.annotations.
+const (
+	AnnotationRunAsUser  = "pvcviewer.k8s.io/run-as-user"
+	AnnotationRunAsGroup = "pvcviewer.k8s.io/run-as-group"
+	AnnotationFSGroup    = "pvcviewer.k8s.io/fs-group"
+	AnnotationReadOnly   = "pvcviewer.k8s.io/read-only"
+)
+
+// EffectiveProfile resolves t under cfg: the security spec with t's security annotations applied
+// and the agent Pod template.
+func EffectiveProfile(cfg *config.Config, t Target) (config.SecuritySpec, config.PodTemplate) {
+	overrides := withClusterOverrides(t, cfg.Agents.SecurityOverrides)
+	sec, matched := ResolveSecurity(cfg.Agents.SecurityDefaults, overrides, t)
+	sec, _ = ApplyAnnotations(sec, cfg.Agents.Annotations, t.Annotations)
+	return sec, ResolvePodTemplate(cfg.Agents.PodTemplate, overrides, matched)
+}
+
+// ResolvePodTemplate merges the Pod templates of the matched overrides (as returned by
+// ResolveSecurity) over base, with the same precedence.
+func ResolvePodTemplate(base config.PodTemplate, overrides []config.OverrideSpec, matched []int) config.PodTemplate {
+	for i := len(matched) - 1; i >= 0; i-- {
+		if tpl := overrides[matched[i]].PodTemplate; tpl != nil {
+			base = config.MergePodTemplate(base, *tpl)
+		}
 	}
-	return psc
+	return base
 }
 
-// BuildSecuritySpec computes effective security spec for given storageClass using config (no kube client needed)
-func BuildSecuritySpec(cfg *config.Config, pvcName string, storageClass string) config.SecuritySpec {
-	out := cfg.Agents.SecurityDefaults
-	for _, o := range cfg.Agents.SecurityOverrides {
-		// pvcMatch takes precedence if provided
-		if o.PvcMatch != "" {
-			if ok, _ := doublestar.Match(o.PvcMatch, pvcName); ok {
-				out = mergeSecurity(out, o.SecuritySpec)
-				break
+// ApplyAnnotations applies PVC security annotations on top of sec within policy. Annotations the
+// policy does not allow are skipped and reported, one message each.
+func ApplyAnnotations(sec config.SecuritySpec, policy config.AnnotationPolicy, annotations map[string]string) (config.SecuritySpec, []string) {
+	rejected := []string{}
+	if v, ok := annotations[AnnotationReadOnly]; ok {
+		switch {
+		case !policy.Enabled:
+			rejected = append(rejected, AnnotationReadOnly+": security annotations are disabled (agents.annotations.enabled)")
+		case v == "true":
+			sec.ReadOnly = true
+		case v == "false":
+			if sec.ReadOnly {
+				rejected = append(rejected, AnnotationReadOnly+": cannot make a read-only profile writable")
+			}
+		default:
+			rejected = append(rejected, fmt.Sprintf("%s: %q is not true or false", AnnotationReadOnly, v))
+		}
+	}
+	ids := []struct {
+		key string
+		rng config.IDRange
+		dst **int64
+	}{
+		{AnnotationRunAsUser, policy.RunAsUser, &sec.RunAsUser},
+		{AnnotationRunAsGroup, policy.RunAsGroup, &sec.RunAsGroup},
+		{AnnotationFSGroup, policy.FSGroup, &sec.FSGroup},
+	}
+	for _, a := range ids {
+		v, ok := annotations[a.key]
+		if !ok {
+			continue
+		}
+		id, err := strconv.ParseInt(v, 10, 64)
+		switch {
+		case !policy.Enabled:
+			rejected = append(rejected, a.key+": security annotations are disabled (agents.annotations.enabled)")
+		case policy.ReadOnlyOnly:
+			rejected = append(rejected, a.key+": only "+AnnotationReadOnly+" is allowed (agents.annotations.readOnlyOnly)")
+		case err != nil || id < 0:
+			rejected = append(rejected, fmt.Sprintf("%s: %q is not a non-negative integer", a.key, v))
+		default:
+			if reason := idRejection(a.key == AnnotationRunAsUser, a.rng, id); reason != "" {
+				rejected = append(rejected, a.key+": "+reason)
+				continue
 			}
+			*a.dst = &id
+		}
+	}
+	return sec, rejected
+}
+
+// idRejection tells why id may not be used within rng, "" when it may. Agents never run as UID 0;
+// group 0 (root) is only allowed when rng explicitly starts at 0.
+func idRejection(user bool, rng config.IDRange, id int64) string {
+	switch {
+	case id == 0 && user:
+		return "agents never run as root"
+	case id == 0 && (rng.Min == nil || *rng.Min > 0):
+		return "group 0 (root) needs an explicit min: 0"
+	case !rng.Contains(id):
+		return fmt.Sprintf("%d is outside the allowed range", id)
+	}
+	return ""
+}
+
+// boundOverride removes the IDs of a PVCViewerPolicy override that the agents.annotations bounds
+// do not allow, with the same rules as ID annotations, and its Pod template, which only the cluster
+// config may set (it can pick priority classes, nodes and pull secrets). It reports one message
+// per removed field.
+func boundOverride(policy config.AnnotationPolicy, o config.OverrideSpec) (config.OverrideSpec, []string) {
+	rejected := []string{}
+	if o.PodTemplate != nil {
+		rejected = append(rejected, "podTemplate: only the cluster config sets Pod templates")
+		o.PodTemplate = nil
+	}
+	for _, id := range []struct {
+		name string
+		rng  config.IDRange
+		v    **int64
+	}{
+		{"runAsUser", policy.RunAsUser, &o.RunAsUser},
+		{"runAsGroup", policy.RunAsGroup, &o.RunAsGroup},
+		{"fsGroup", policy.FSGroup, &o.FSGroup},
+	} {
+		if *id.v == nil {
 			continue
 		}
-		if ok, _ := doublestar.Match(o.Match, storageClass); ok {
-			out = mergeSecurity(out, o.SecuritySpec)
-			break
+		if reason := idRejection(id.name == "runAsUser", id.rng, **id.v); reason != "" {
+			rejected = append(rejected, id.name+": "+reason)
+			*id.v = nil
+		}
+	}
+	// supplemental groups are bounded like runAsGroup
+	var supp []int64
+	for _, g := range o.SupplementalGroups {
+		if reason := idRejection(false, policy.RunAsGroup, g); reason != "" {
+			rejected = append(rejected, "supplementalGroups: "+reason)
+			continue
 		}
+		supp = append(supp, g)
 	}
-	return out
+	o.SupplementalGroups = supp
+	return o, rejected
 }
 
 // ProfileKey returns stable short key for a security spec used to derive group hash
@@ -82,6 +219,17 @@ func ProfileKey(s config.SecuritySpec) string {
 	return hex.EncodeToString(sum[:8])
 }
 
+// GroupKey identifies a namespace agent group: ProfileKey(sec), extended with the Pod template
+// when one is set so PVCs with different templates get different agents.
+func GroupKey(sec config.SecuritySpec, tpl config.PodTemplate) string {
+	k := tpl.Key()
+	if k == "" {
+		return ProfileKey(sec)
+	}
+	sum := sha1.Sum([]byte(ProfileKey(sec) + "|" + k))
+	return hex.EncodeToString(sum[:8])
+}
+
 // mergeSecurity merges override into base
 func mergeSecurity(base config.SecuritySpec, o config.SecuritySpec) config.SecuritySpec {
 	if o.RunAsUser != nil {
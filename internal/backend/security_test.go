@@ -0,0 +1,128 @@
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
+)
+
+func i64(v int64) *int64 { return &v }
+
+func TestApplyAnnotations(t *testing.T) {
+	base := config.SecuritySpec{RunAsUser: i64(65532), RunAsGroup: i64(65532)}
+	open := config.AnnotationPolicy{Enabled: true}
+	bounded := config.AnnotationPolicy{Enabled: true, RunAsUser: config.IDRange{Min: i64(1000), Max: i64(2000)}, FSGroup: config.IDRange{Min: i64(0)}, RunAsGroup: config.IDRange{Max: i64(5000)}}
+	tests := []struct {
+		name        string
+		base        config.SecuritySpec
+		policy      config.AnnotationPolicy
+		annotations map[string]string
+		want        config.SecuritySpec
+		// rejected lists substrings, one per expected rejection
+		rejected []string
+	}{
+		{name: "none", base: base, policy: open, want: base},
+		{name: "disabled", base: base, policy: config.AnnotationPolicy{}, annotations: map[string]string{AnnotationRunAsUser: "1000", AnnotationReadOnly: "true"}, want: base, rejected: []string{"disabled", "disabled"}},
+		{name: "ids within bounds", base: base, policy: bounded, annotations: map[string]string{AnnotationRunAsUser: "1500", AnnotationRunAsGroup: "3000", AnnotationFSGroup: "4000"},
+			want: config.SecuritySpec{RunAsUser: i64(1500), RunAsGroup: i64(3000), FSGroup: i64(4000)}},
+		{name: "outside bounds", base: base, policy: bounded, annotations: map[string]string{AnnotationRunAsUser: "999", AnnotationRunAsGroup: "5001"}, want: base, rejected: []string{"outside", "outside"}},
+		{name: "uid 0 never", base: base, policy: config.AnnotationPolicy{Enabled: true, RunAsUser: config.IDRange{Min: i64(0)}}, annotations: map[string]string{AnnotationRunAsUser: "0"}, want: base, rejected: []string{"never run as root"}},
+		{name: "gid 0 without explicit min", base: base, policy: open, annotations: map[string]string{AnnotationRunAsGroup: "0", AnnotationFSGroup: "0"}, want: base, rejected: []string{"group 0", "group 0"}},
+		{name: "gid 0 with min 0", base: base, policy: bounded, annotations: map[string]string{AnnotationFSGroup: "0"}, want: config.SecuritySpec{RunAsUser: i64(65532), RunAsGroup: i64(65532), FSGroup: i64(0)}},
+		{name: "gid 0 with max only", base: base, policy: bounded, annotations: map[string]string{AnnotationRunAsGroup: "0"}, want: base, rejected: []string{"group 0"}},
+		{name: "not a number", base: base, policy: open, annotations: map[string]string{AnnotationFSGroup: "-5", AnnotationRunAsUser: "abc"}, want: base, rejected: []string{"non-negative", "non-negative"}},
+		{name: "read-only only", base: base, policy: config.AnnotationPolicy{Enabled: true, ReadOnlyOnly: true}, annotations: map[string]string{AnnotationRunAsUser: "1000", AnnotationReadOnly: "true"},
+			want: config.SecuritySpec{RunAsUser: i64(65532), RunAsGroup: i64(65532), ReadOnly: true}, rejected: []string{"readOnlyOnly"}},
+		{name: "cannot loosen read-only", base: config.SecuritySpec{ReadOnly: true}, policy: open, annotations: map[string]string{AnnotationReadOnly: "false"}, want: config.SecuritySpec{ReadOnly: true}, rejected: []string{"writable"}},
+		{name: "bad read-only", base: base, policy: open, annotations: map[string]string{AnnotationReadOnly: "yes"}, want: base, rejected: []string{"not true or false"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rejected := ApplyAnnotations(tt.base, tt.policy, tt.annotations)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("spec %s, want %s", specString(got), specString(tt.want))
+			}
+			if len(rejected) != len(tt.rejected) {
+				t.Fatalf("rejected %q, want %d", rejected, len(tt.rejected))
+			}
+			for _, want := range tt.rejected {
+				found := false
+				for _, r := range rejected {
+					found = found || strings.Contains(r, want)
+				}
+				if !found {
+					t.Errorf("rejected %q, want one mentioning %q", rejected, want)
+				}
+			}
+		})
+	}
+}
+
+func specString(s config.SecuritySpec) string {
+	id := func(p *int64) string {
+		if p == nil {
+			return "-"
+		}
+		return strconv.FormatInt(*p, 10)
+	}
+	return fmt.Sprintf("ru=%s rg=%s fg=%s supp=%v ro=%t", id(s.RunAsUser), id(s.RunAsGroup), id(s.FSGroup), s.SupplementalGroups, s.ReadOnly)
+}
+
+func TestResolveSecurity(t *testing.T) {
+	defaults := config.SecuritySpec{RunAsUser: i64(65532), RunAsGroup: i64(65532), SupplementalGroups: []int64{10}}
+	target := Target{Namespace: "team-a", PVCName: "shared-ml", StorageClass: "cephfs", Labels: map[string]string{"tier": "gold"}}
+	tests := []struct {
+		name        string
+		overrides   []config.OverrideSpec
+		want        config.SecuritySpec
+		wantMatched []int
+	}{
+		{name: "no overrides", want: defaults, wantMatched: []int{}},
+		{name: "no conditions matches nothing", overrides: []config.OverrideSpec{{SecuritySpec: config.SecuritySpec{RunAsUser: i64(1)}}}, want: defaults, wantMatched: []int{}},
+		{name: "all conditions must hold", overrides: []config.OverrideSpec{
+			{Namespace: "team-*", Match: "nfs", SecuritySpec: config.SecuritySpec{RunAsUser: i64(1)}},
+			{Namespace: "team-*", PvcMatch: "shared-*", Match: "ceph*", Selector: "tier=gold", SecuritySpec: config.SecuritySpec{RunAsUser: i64(2)}},
+		}, want: config.SecuritySpec{RunAsUser: i64(2), RunAsGroup: i64(65532), SupplementalGroups: []int64{10}}, wantMatched: []int{1}},
+		{name: "selector mismatch", overrides: []config.OverrideSpec{{Selector: "tier=silver", SecuritySpec: config.SecuritySpec{RunAsUser: i64(1)}}}, want: defaults, wantMatched: []int{}},
+		{name: "merged field by field", overrides: []config.OverrideSpec{
+			{Namespace: "team-a", SecuritySpec: config.SecuritySpec{FSGroup: i64(3000)}},
+			{Match: "cephfs", SecuritySpec: config.SecuritySpec{RunAsGroup: i64(4000), SupplementalGroups: []int64{20}}},
+		}, want: config.SecuritySpec{RunAsUser: i64(65532), RunAsGroup: i64(4000), FSGroup: i64(3000), SupplementalGroups: []int64{20}}, wantMatched: []int{0, 1}},
+		{name: "first listed wins a tie", overrides: []config.OverrideSpec{
+			{Namespace: "team-a", SecuritySpec: config.SecuritySpec{FSGroup: i64(1)}},
+			{Namespace: "team-a", SecuritySpec: config.SecuritySpec{FSGroup: i64(2)}},
+		}, want: config.SecuritySpec{RunAsUser: i64(65532), RunAsGroup: i64(65532), FSGroup: i64(1), SupplementalGroups: []int64{10}}, wantMatched: []int{0, 1}},
+		{name: "higher priority wins", overrides: []config.OverrideSpec{
+			{Namespace: "team-a", SecuritySpec: config.SecuritySpec{FSGroup: i64(1)}},
+			{Namespace: "team-a", Priority: 10, SecuritySpec: config.SecuritySpec{FSGroup: i64(2)}},
+		}, want: config.SecuritySpec{RunAsUser: i64(65532), RunAsGroup: i64(65532), FSGroup: i64(2), SupplementalGroups: []int64{10}}, wantMatched: []int{1, 0}},
+		{name: "read-only from any match", overrides: []config.OverrideSpec{
+			{Namespace: "team-a", Priority: 10, SecuritySpec: config.SecuritySpec{FSGroup: i64(1)}},
+			{PvcMatch: "shared-*", SecuritySpec: config.SecuritySpec{ReadOnly: true}},
+		}, want: config.SecuritySpec{RunAsUser: i64(65532), RunAsGroup: i64(65532), FSGroup: i64(1), SupplementalGroups: []int64{10}, ReadOnly: true}, wantMatched: []int{0, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, matched := ResolveSecurity(defaults, tt.overrides, target)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("spec %s, want %s", specString(got), specString(tt.want))
+			}
+			if !reflect.DeepEqual(matched, tt.wantMatched) {
+				t.Errorf("matched %v, want %v", matched, tt.wantMatched)
+			}
+		})
+	}
+}
+
+func TestResolveSecurityPolicyOverridesFirst(t *testing.T) {
+	cluster := []config.OverrideSpec{{Namespace: "team-a", SecuritySpec: config.SecuritySpec{FSGroup: i64(1)}}}
+	target := Target{Namespace: "team-a", PVCName: "data", Overrides: []config.OverrideSpec{{PvcMatch: "data", SecuritySpec: config.SecuritySpec{FSGroup: i64(2)}}}}
+	got, matched := ResolveSecurity(config.SecuritySpec{}, withClusterOverrides(target, cluster), target)
+	if *got.FSGroup != 2 || !reflect.DeepEqual(matched, []int{0, 1}) {
+		t.Errorf("fsGroup %d matched %v, want the policy override first", *got.FSGroup, matched)
+	}
+}
@@ -2,9 +2,15 @@ package backend
 
 import (
 	"context"
+	"fmt"
 
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/kubernetes"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
 )
 
 type PVCStatus string
@@ -14,22 +20,180 @@ const (
 	StatusAgentPending PVCStatus = "AgentPending"
 	StatusMountBlocked PVCStatus = "MountBlocked"
 	StatusReadOnly     PVCStatus = "ReadOnly"
+	// StatusUnbound: the PVC is Pending or Lost, so no agent can mount it
+	StatusUnbound PVCStatus = "Unbound"
+	// StatusAgentError: the agent Pod failed or a container cannot start (image pull, crash loop)
+	StatusAgentError PVCStatus = "AgentError"
+	StatusNotFound   PVCStatus = "NotFound"
 )
 
-type StatusService struct{ Client kubernetes.Interface }
+// StatusReport is the result of /api/v1/pvc-status. Reason is a short CamelCase cause (often the
+// Kubernetes reason), Message a human-readable detail.
+type StatusReport struct {
+	Status  PVCStatus `json:"status"`
+	Reason  string    `json:"reason,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+// agentErrorReasons are container waiting reasons that will not resolve without intervention.
+var agentErrorReasons = map[string]bool{
+	"ErrImagePull":               true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"CrashLoopBackOff":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+	"RunContainerError":          true,
+}
+
+// mountEventReasons are Pod events reporting that the PVC cannot be attached or mounted.
+var mountEventReasons = map[string]bool{
+	"FailedMount":        true,
+	"FailedAttachVolume": true,
+}
+
+// StatusService derives the status of a PVC from the PVC itself and the agent serving it.
+type StatusService struct {
+	Client kubernetes.Interface
+	// Disc resolves storage classes and reads PVCs/agent Pods from its cache when set.
+	Disc *Discovery
+	// Mounts answers for mount-in-backend mode.
+	Mounts *MountDataPlane
+}
+
+func (s *StatusService) GetStatus(ctx context.Context, cfg *config.Config, ns, pvc string) (StatusReport, error) {
+	claim, err := s.Disc.getPVC(ctx, ns, pvc)
+	if apierrors.IsNotFound(err) {
+		return StatusReport{Status: StatusNotFound, Reason: "PVCNotFound", Message: fmt.Sprintf("PersistentVolumeClaim %s/%s does not exist", ns, pvc)}, nil
+	}
+	if err != nil {
+		return StatusReport{}, err
+	}
+	switch claim.Status.Phase {
+	case corev1.ClaimPending:
+		return StatusReport{Status: StatusUnbound, Reason: "Pending", Message: "PersistentVolumeClaim is not bound to a volume yet"}, nil
+	case corev1.ClaimLost:
+		return StatusReport{Status: StatusUnbound, Reason: "Lost", Message: "the bound PersistentVolume no longer exists"}, nil
+	}
+
+	if cfg.Mode.DataPlane == ModeMountInBackend {
+		// no agent involved: either the PVC is mounted into this Pod or it is not (yet)
+		srv, ok := s.Mounts.Lookup(ns, pvc)
+		if !ok {
+			return StatusReport{Status: StatusMountBlocked, Reason: "NotMounted", Message: "PVC is not mounted into the backend; add it to mountPVCs and roll out the backend"}, nil
+		}
+		if srv.ReadOnly {
+			return StatusReport{Status: StatusReadOnly, Reason: "ReadOnly", Message: "mounted read-only"}, nil
+		}
+		return StatusReport{Status: StatusReady}, nil
+	}
+
+	name := AgentServiceName(cfg, s.Disc.Target(ctx, cfg, ns, pvc))
+	pod, err := s.getAgentPod(ctx, ns, name)
+	if apierrors.IsNotFound(err) {
+		return StatusReport{Status: StatusAgentPending, Reason: "AgentNotCreated", Message: fmt.Sprintf("agent Pod %s does not exist yet", name)}, nil
+	}
+	if err != nil {
+		return StatusReport{}, err
+	}
+	if pod.DeletionTimestamp != nil {
+		return StatusReport{Status: StatusAgentPending, Reason: "Terminating", Message: "agent Pod is being replaced"}, nil
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		if w := cs.State.Waiting; w != nil && agentErrorReasons[w.Reason] {
+			return StatusReport{Status: StatusAgentError, Reason: w.Reason, Message: w.Message}, nil
+		}
+	}
+	if pod.Status.Phase == corev1.PodFailed {
+		reason := pod.Status.Reason
+		if reason == "" {
+			reason = "PodFailed"
+		}
+		return StatusReport{Status: StatusAgentError, Reason: reason, Message: pod.Status.Message}, nil
+	}
+	if podReady(pod) {
+		if mountReadOnly(pod, pvc) {
+			return StatusReport{Status: StatusReadOnly, Reason: "ReadOnly", Message: "agent mounts the PVC read-only"}, nil
+		}
+		return StatusReport{Status: StatusReady}, nil
+	}
+	if ev, err := s.lastMountEvent(ctx, pod); err == nil && ev != nil {
+		return StatusReport{Status: StatusMountBlocked, Reason: ev.Reason, Message: ev.Message}, nil
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodScheduled && c.Status == corev1.ConditionFalse {
+			return StatusReport{Status: StatusAgentPending, Reason: c.Reason, Message: c.Message}, nil
+		}
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		if w := cs.State.Waiting; w != nil {
+			return StatusReport{Status: StatusAgentPending, Reason: w.Reason, Message: w.Message}, nil
+		}
+	}
+	return StatusReport{Status: StatusAgentPending, Reason: "NotReady", Message: fmt.Sprintf("agent Pod is %s and not ready", pod.Status.Phase)}, nil
+}
+
+func (s *StatusService) getAgentPod(ctx context.Context, ns, name string) (*corev1.Pod, error) {
+	if s.Disc != nil && s.Disc.Cache != nil {
+		return s.Disc.Cache.AgentPods.Pods(ns).Get(name)
+	}
+	return s.Client.CoreV1().Pods(ns).Get(ctx, name, metav1.GetOptions{})
+}
 
-func (s *StatusService) GetStatus(ctx context.Context, ns, pvc string) (PVCStatus, error) {
-	// Simplified: if agent Pod exists and Ready -> Ready, else AgentPending
-	name := AgentName(ns, pvc)
-	pod, err := s.Client.CoreV1().Pods(ns).Get(ctx, name, metav1.GetOptions{})
+// lastMountEvent returns the most recent FailedMount/FailedAttachVolume event of pod, if any.
+func (s *StatusService) lastMountEvent(ctx context.Context, pod *corev1.Pod) (*corev1.Event, error) {
+	sel := fields.Set{"involvedObject.kind": "Pod", "involvedObject.name": pod.Name, "involvedObject.uid": string(pod.UID)}.AsSelector().String()
+	evs, err := s.Client.CoreV1().Events(pod.Namespace).List(ctx, metav1.ListOptions{FieldSelector: sel})
 	if err != nil {
-		return StatusAgentPending, nil
+		return nil, err
+	}
+	var last *corev1.Event
+	for i := range evs.Items {
+		ev := &evs.Items[i]
+		if !mountEventReasons[ev.Reason] {
+			continue
+		}
+		if last == nil || eventTime(ev).After(eventTime(last).Time) {
+			last = ev
+		}
 	}
+	return last, nil
+}
+
+func eventTime(ev *corev1.Event) metav1.Time {
+	if !ev.LastTimestamp.IsZero() {
+		return ev.LastTimestamp
+	}
+	if !ev.EventTime.IsZero() {
+		return metav1.NewTime(ev.EventTime.Time)
+	}
+	return ev.CreationTimestamp
+}
+
+func podReady(pod *corev1.Pod) bool {
 	for _, c := range pod.Status.Conditions {
-		if c.Type == "Ready" && c.Status == "True" {
-			return StatusReady, nil
+		if c.Type == corev1.PodReady && c.Status == corev1.ConditionTrue {
+			return true
 		}
 	}
-	return StatusAgentPending, nil
+	return false
 }
 
+// mountReadOnly reports whether the agent mounts pvc read-only (the effective flag after overrides).
+func mountReadOnly(pod *corev1.Pod, pvc string) bool {
+	vol := ""
+	for _, v := range pod.Spec.Volumes {
+		if v.PersistentVolumeClaim != nil && v.PersistentVolumeClaim.ClaimName == pvc {
+			vol = v.Name
+			break
+		}
+	}
+	for _, c := range pod.Spec.Containers {
+		for _, m := range c.VolumeMounts {
+			if m.Name == vol {
+				return m.ReadOnly
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package backend
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/valeriikretinin/kubernetes-pvc-viewer/internal/config"
+)
+
+func TestGetStatus(t *testing.T) {
+	claim := func(phase corev1.PersistentVolumeClaimPhase) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "data"},
+			Status:     corev1.PersistentVolumeClaimStatus{Phase: phase},
+		}
+	}
+	// agent is the agent Pod of ns/data mounting it with readOnly, changed by each case
+	agent := func(readOnly bool, change func(*corev1.Pod)) *corev1.Pod {
+		p := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: AgentName("ns", "data"), UID: "agent-uid"},
+			Spec: corev1.PodSpec{
+				Volumes: []corev1.Volume{{Name: "pvc", VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "data"},
+				}}},
+				Containers: []corev1.Container{{Name: "agent", VolumeMounts: []corev1.VolumeMount{{Name: "pvc", MountPath: "/data", ReadOnly: readOnly}}}},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning, Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}},
+		}
+		if change != nil {
+			change(p)
+		}
+		return p
+	}
+	notReady := func(p *corev1.Pod) {
+		p.Status.Phase = corev1.PodPending
+		p.Status.Conditions = nil
+	}
+	waiting := func(reason string) func(*corev1.Pod) {
+		return func(p *corev1.Pod) {
+			notReady(p)
+			p.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "agent", State: corev1.ContainerState{
+				Waiting: &corev1.ContainerStateWaiting{Reason: reason, Message: reason + " message"},
+			}}}
+		}
+	}
+	event := func(reason, msg string, at int64) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Namespace: "ns", Name: reason + "-" + msg},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: "ns", Name: AgentName("ns", "data"), UID: "agent-uid"},
+			Reason:         reason,
+			Message:        msg,
+			LastTimestamp:  metav1.Unix(at, 0),
+		}
+	}
+
+	for _, c := range []struct {
+		name    string
+		objs    []runtime.Object
+		want    PVCStatus
+		reason  string
+		message string
+	}{
+		{"no pvc", nil, StatusNotFound, "PVCNotFound", ""},
+		{"pending", []runtime.Object{claim(corev1.ClaimPending)}, StatusUnbound, "Pending", ""},
+		{"lost", []runtime.Object{claim(corev1.ClaimLost), agent(false, nil)}, StatusUnbound, "Lost", ""},
+		{"no agent", []runtime.Object{claim(corev1.ClaimBound)}, StatusAgentPending, "AgentNotCreated", ""},
+		{"ready", []runtime.Object{claim(corev1.ClaimBound), agent(false, nil)}, StatusReady, "", ""},
+		{"read-only", []runtime.Object{claim(corev1.ClaimBound), agent(true, nil)}, StatusReadOnly, "ReadOnly", ""},
+		{"image pull", []runtime.Object{claim(corev1.ClaimBound), agent(false, waiting("ImagePullBackOff"))}, StatusAgentError, "ImagePullBackOff", "ImagePullBackOff message"},
+		{"crash loop", []runtime.Object{claim(corev1.ClaimBound), agent(false, waiting("CrashLoopBackOff"))}, StatusAgentError, "CrashLoopBackOff", "CrashLoopBackOff message"},
+		{"pod failed", []runtime.Object{claim(corev1.ClaimBound), agent(false, func(p *corev1.Pod) {
+			notReady(p)
+			p.Status.Phase, p.Status.Reason, p.Status.Message = corev1.PodFailed, "Evicted", "node out of disk"
+		})}, StatusAgentError, "Evicted", "node out of disk"},
+		{"pod failed without reason", []runtime.Object{claim(corev1.ClaimBound), agent(false, func(p *corev1.Pod) {
+			notReady(p)
+			p.Status.Phase = corev1.PodFailed
+		})}, StatusAgentError, "PodFailed", ""},
+		{"mount blocked", []runtime.Object{
+			claim(corev1.ClaimBound), agent(false, waiting("ContainerCreating")),
+			event("FailedAttachVolume", "older", 100), event("FailedMount", "newest", 200), event("Scheduled", "unrelated", 300),
+		}, StatusMountBlocked, "FailedMount", "newest"},
+		{"ready despite old mount event", []runtime.Object{
+			claim(corev1.ClaimBound), agent(false, nil), event("FailedMount", "retried", 100),
+		}, StatusReady, "", ""},
+		{"unschedulable", []runtime.Object{claim(corev1.ClaimBound), agent(false, func(p *corev1.Pod) {
+			notReady(p)
+			p.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: "Unschedulable", Message: "0/3 nodes"}}
+		})}, StatusAgentPending, "Unschedulable", "0/3 nodes"},
+		{"creating", []runtime.Object{claim(corev1.ClaimBound), agent(false, waiting("ContainerCreating"))}, StatusAgentPending, "ContainerCreating", "ContainerCreating message"},
+		{"terminating", []runtime.Object{claim(corev1.ClaimBound), agent(false, func(p *corev1.Pod) {
+			now := metav1.Now()
+			p.DeletionTimestamp = &now
+		})}, StatusAgentPending, "Terminating", ""},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(c.objs...)
+			s := &StatusService{Client: client, Disc: &Discovery{Client: client}}
+			got, err := s.GetStatus(context.Background(), &config.Config{}, "ns", "data")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != c.want || got.Reason != c.reason {
+				t.Errorf("got %s/%s (%s), want %s/%s", got.Status, got.Reason, got.Message, c.want, c.reason)
+			}
+			if c.message != "" && got.Message != c.message {
+				t.Errorf("message %q, want %q", got.Message, c.message)
+			}
+		})
+	}
+}
+
+func TestGetStatusMountInBackend(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "rw"}, Status: corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound}},
+		&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "ro"}, Status: corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound}},
+		&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "other"}, Status: corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound}},
+	)
+	mounts := NewMountDataPlane("ns", zap.NewNop().Sugar())
+	cfg := &config.Config{MountPVCs: []config.MountPVC{{PvcName: "rw", MountPath: t.TempDir()}, {PvcName: "ro", MountPath: t.TempDir(), ReadOnly: true}}}
+	cfg.Mode.DataPlane = ModeMountInBackend
+	mounts.Apply(cfg)
+	s := &StatusService{Client: client, Disc: &Discovery{Client: client, Mounts: mounts}, Mounts: mounts}
+
+	for pvc, want := range map[string]StatusReport{
+		"rw":    {Status: StatusReady},
+		"ro":    {Status: StatusReadOnly, Reason: "ReadOnly"},
+		"other": {Status: StatusMountBlocked, Reason: "NotMounted"},
+	} {
+		got, err := s.GetStatus(context.Background(), cfg, "ns", pvc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != want.Status || got.Reason != want.Reason {
+			t.Errorf("%s: got %s/%s, want %s/%s", pvc, got.Status, got.Reason, want.Status, want.Reason)
+		}
+	}
+}
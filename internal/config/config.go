@@ -5,31 +5,135 @@ import (
 	"io/ioutil"
 	"path/filepath"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"go.uber.org/zap"
-	"gopkg.in/yaml.v3"
 )
 
 type WatchSet struct {
-	Include []string `yaml:"include"`
-	Exclude []string `yaml:"exclude"`
+	Include []string `yaml:"include" json:"include,omitempty"`
+	Exclude []string `yaml:"exclude" json:"exclude,omitempty"`
+	// Selector is a label selector the object must also match (namespaces and PVCs only)
+	Selector string `yaml:"selector" json:"selector,omitempty"`
+	// OptIn requires the annotation pvcviewer.k8s.io/enabled: "true" (namespaces and PVCs only);
+	// "false" always opts an object out
+	OptIn bool `yaml:"optIn" json:"optIn,omitempty"`
 }
 
 type SecuritySpec struct {
-	RunAsUser          *int64  `yaml:"runAsUser"`
-	RunAsGroup         *int64  `yaml:"runAsGroup"`
-	FSGroup            *int64  `yaml:"fsGroup"`
-	SupplementalGroups []int64 `yaml:"supplementalGroups"`
-	ReadOnly           bool    `yaml:"readOnly"`
+	RunAsUser          *int64  `yaml:"runAsUser" json:"runAsUser,omitempty"`
+	RunAsGroup         *int64  `yaml:"runAsGroup" json:"runAsGroup,omitempty"`
+	FSGroup            *int64  `yaml:"fsGroup" json:"fsGroup,omitempty"`
+	SupplementalGroups []int64 `yaml:"supplementalGroups" json:"supplementalGroups,omitempty"`
+	ReadOnly           bool    `yaml:"readOnly" json:"readOnly"`
 }
 
+// OverrideSpec applies SecuritySpec to PVCs matching all of its set conditions; see
+// backend.ResolveSecurity for how several matching overrides are merged.
 type OverrideSpec struct {
-	Match        string `yaml:"match"`    // storageClass glob
-	PvcMatch     string `yaml:"pvcMatch"` // optional PVC name glob (takes precedence over Match)
-	SecuritySpec `yaml:",inline"`
+	Match     string `yaml:"match" json:"match,omitempty"`         // storageClass glob
+	PvcMatch  string `yaml:"pvcMatch" json:"pvcMatch,omitempty"`   // PVC name glob
+	Namespace string `yaml:"namespace" json:"namespace,omitempty"` // namespace glob
+	Selector  string `yaml:"selector" json:"selector,omitempty"`   // PVC label selector
+	// Priority orders matching overrides: higher wins per field, list order breaks ties
+	Priority     int `yaml:"priority" json:"priority,omitempty"`
+	SecuritySpec `yaml:",inline" json:",inline"`
+	// PodTemplate is merged over agents.podTemplate (see MergePodTemplate)
+	PodTemplate *PodTemplate `yaml:"podTemplate" json:"podTemplate,omitempty"`
+}
+
+// IDRange bounds a UID/GID; nil ends are open.
+type IDRange struct {
+	Min *int64 `yaml:"min"`
+	Max *int64 `yaml:"max"`
+}
+
+// Contains reports whether id lies within the range.
+func (r IDRange) Contains(id int64) bool {
+	return (r.Min == nil || id >= *r.Min) && (r.Max == nil || id <= *r.Max)
+}
+
+// AnnotationPolicy controls per-PVC security annotations (pvcviewer.k8s.io/run-as-user,
+// run-as-group, fs-group, read-only). Annotations are applied after securityOverrides.
+type AnnotationPolicy struct {
+	Enabled bool `yaml:"enabled"`
+	// ReadOnlyOnly: annotations may only tighten to read-only; ID annotations are rejected
+	ReadOnlyOnly bool    `yaml:"readOnlyOnly"`
+	RunAsUser    IDRange `yaml:"runAsUser"`
+	RunAsGroup   IDRange `yaml:"runAsGroup"`
+	FSGroup      IDRange `yaml:"fsGroup"`
+}
+
+// MountPVC describes a PVC mounted into the backend Pod (mode "mount-in-backend").
+// The volume itself is rendered by Helm; the backend only needs the mount path.
+type MountPVC struct {
+	PvcName   string `yaml:"pvcName"`
+	MountPath string `yaml:"mountPath"`
+	ReadOnly  bool   `yaml:"readOnly"`
+	SubPath   string `yaml:"subPath"`
+}
+
+// ClaimMapping maps verified token claims to the caller identity.
+type ClaimMapping struct {
+	UsernameClaim  string `yaml:"usernameClaim"` // default "sub"
+	GroupsClaim    string `yaml:"groupsClaim"`   // default "groups"
+	UsernamePrefix string `yaml:"usernamePrefix"`
+	GroupsPrefix   string `yaml:"groupsPrefix"`
+}
+
+// OIDCSpec configures bearer JWT validation and, when redirectURL is set, the browser login flow.
+// The client secret is read from the environment, never from the ConfigMap.
+type OIDCSpec struct {
+	IssuerURL    string   `yaml:"issuerURL"`
+	JWKSURL      string   `yaml:"jwksURL"` // optional; otherwise taken from issuer discovery
+	ClientID     string   `yaml:"clientID"`
+	RedirectURL  string   `yaml:"redirectURL"`
+	Scopes       []string `yaml:"scopes"`
+	Audiences    []string `yaml:"audiences"` // accepted bearer audiences (default: clientID)
+	ClaimMapping `yaml:",inline"`
+}
+
+// ServiceAccountAuthSpec enables bearer authentication with Kubernetes tokens via TokenReview.
+type ServiceAccountAuthSpec struct {
+	Enabled        bool          `yaml:"enabled"`
+	Audiences      []string      `yaml:"audiences"` // required; tokens must be projected for one of these
+	UsernamePrefix string        `yaml:"usernamePrefix"`
+	GroupsPrefix   string        `yaml:"groupsPrefix"`
+	CacheTTL       time.Duration `yaml:"cacheTTL"`
+}
+
+type AuthSpec struct {
+	Enabled         bool                   `yaml:"enabled"`
+	OIDC            OIDCSpec               `yaml:"oidc"`
+	ServiceAccounts ServiceAccountAuthSpec `yaml:"serviceAccounts"`
+	Session         struct {
+		CookieName string        `yaml:"cookieName"`
+		TTL        time.Duration `yaml:"ttl"`
+	} `yaml:"session"`
+}
+
+// RBACRule grants verbs on namespace/PVC globs to users or groups.
+// Empty users and groups apply the rule to every caller; empty verbs grant all verbs.
+type RBACRule struct {
+	Users      []string `yaml:"users"`
+	Groups     []string `yaml:"groups"`
+	Namespaces []string `yaml:"namespaces"`
+	PVCs       []string `yaml:"pvcs"`
+	Verbs      []string `yaml:"verbs"` // list, download, upload, delete, empty, admin
+}
+
+// AuditSink configures one audit destination.
+type AuditSink struct {
+	Type       string            `yaml:"type"` // stdout | file | rotating-file | webhook
+	Path       string            `yaml:"path"` // file, rotating-file
+	MaxSizeMB  int               `yaml:"maxSizeMB"`
+	MaxBackups int               `yaml:"maxBackups"`
+	URL        string            `yaml:"url"` // webhook
+	Headers    map[string]string `yaml:"headers"`
+	Timeout    time.Duration     `yaml:"timeout"`
 }
 
 type Config struct {
@@ -41,35 +145,133 @@ type Config struct {
 	Mode struct {
 		DataPlane string `yaml:"dataPlane"`
 	} `yaml:"mode"`
-	Agents struct {
+	MountPVCs []MountPVC `yaml:"mountPVCs"`
+	Agents    struct {
 		SecurityDefaults  SecuritySpec   `yaml:"securityDefaults"`
 		SecurityOverrides []OverrideSpec `yaml:"securityOverrides"`
+		// Annotations lets PVC owners adjust their agent's security profile within bounds
+		Annotations AnnotationPolicy `yaml:"annotations"`
+		// PodTemplate adds resources, scheduling, pull secrets and metadata to agent Pods
+		PodTemplate PodTemplate `yaml:"podTemplate"`
+		// CleanupOnShutdown: never (default) | always | uninstall
+		CleanupOnShutdown string `yaml:"cleanupOnShutdown"`
 	} `yaml:"agents"`
+	// Auth is applied at startup; the chart restarts the backend on ConfigMap changes.
+	Auth AuthSpec `yaml:"auth"`
+	RBAC struct {
+		// Mode selects the authorizer: "rules" (default) or "kubernetes" (SubjectAccessReview)
+		Mode     string        `yaml:"mode"`
+		CacheTTL time.Duration `yaml:"cacheTTL"` // kubernetes mode; applied at startup
+		Rules    []RBACRule    `yaml:"rules"`    // no rules => everything allowed
+	} `yaml:"rbac"`
+	Audit struct {
+		Sinks []AuditSink `yaml:"sinks"` // no sinks => audit disabled
+	} `yaml:"audit"`
+	// Policies controls how PVCViewerPolicy objects are merged (see policy.go)
+	Policies struct {
+		// AllowSecurityOverrides honours spec.securityOverrides of policies; off by default since
+		// anyone who can edit a policy could otherwise pick the agent's UID/GID
+		AllowSecurityOverrides bool `yaml:"allowSecurityOverrides"`
+	} `yaml:"policies"`
+
+	hash string // sha256 of the source document, set by Parse
 }
 
+// Hash returns the content hash of the document the config was parsed from.
+func (c *Config) Hash() string { return c.hash }
+
 type State struct {
 	cfg atomic.Value // *Config
+
+	mu        sync.Mutex
+	loadedAt  time.Time
+	lastErr   error
+	lastErrAt time.Time
+	history   []Reload
 }
 
 func NewState() *State { s := &State{}; s.cfg.Store(&Config{}); return s }
 
 func (s *State) Current() *Config { return s.cfg.Load().(*Config) }
 
-func (s *State) ApplyNewConfig(c *Config) { s.cfg.Store(c) }
+// ApplyNewConfig makes c current and records the reload with a diff against the previous config.
+// Re-applying identical content (fsnotify fires several events per ConfigMap update) is not recorded.
+func (s *State) ApplyNewConfig(c *Config) {
+	prev := s.Current()
+	s.cfg.Store(c)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	changed := s.loadedAt.IsZero() || prev.hash != c.hash || c.hash == ""
+	s.loadedAt, s.lastErr, s.lastErrAt = now, nil, time.Time{}
+	if changed {
+		s.record(Reload{Time: now, Applied: true, Hash: c.hash, Changes: Diff(prev, c)})
+	}
+}
 
+// RejectConfig records a failed load; the last good config stays current.
+func (s *State) RejectConfig(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	if s.lastErr != nil && s.lastErr.Error() == err.Error() {
+		return
+	}
+	s.lastErr, s.lastErrAt = err, now
+	s.record(Reload{Time: now, Error: err.Error()})
+}
+
+func (s *State) record(r Reload) {
+	s.history = append(s.history, r)
+	if len(s.history) > historySize {
+		s.history = s.history[len(s.history)-historySize:]
+	}
+}
+
+// Loaded reports whether a valid config has been applied.
+func (s *State) Loaded() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.loadedAt.IsZero()
+}
+
+// LastError returns the error of the most recent load if it was rejected.
+func (s *State) LastError() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastErr
+}
+
+// Status describes the applied config and recent reloads (newest first).
+func (s *State) Status() StateStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := StateStatus{Hash: s.Current().hash, History: make([]Reload, 0, len(s.history))}
+	if !s.loadedAt.IsZero() {
+		t := s.loadedAt
+		st.LoadedAt = &t
+	}
+	if s.lastErr != nil {
+		st.LastError = s.lastErr.Error()
+		t := s.lastErrAt
+		st.LastErrorAt = &t
+	}
+	for i := len(s.history) - 1; i >= 0; i-- {
+		st.History = append(st.History, s.history[i])
+	}
+	return st
+}
+
+// Load reads and strictly parses the config file (see Parse).
 func Load(path string) (*Config, error) {
 	b, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	var c Config
-	if err := yaml.Unmarshal(b, &c); err != nil {
-		return nil, err
-	}
-	return &c, nil
+	return Parse(b)
 }
 
-func WatchFile(ctx context.Context, path string, onChange func(*Config)) error {
+func WatchFile(ctx context.Context, path string, onChange func(*Config), onError func(error)) error {
 	logger, _ := zap.NewProduction()
 	defer logger.Sync()
 	sugar := logger.Sugar()
@@ -78,6 +280,7 @@ func WatchFile(ctx context.Context, path string, onChange func(*Config)) error {
 		onChange(cfg)
 	} else {
 		sugar.Warnw("failed to load initial config", "error", err)
+		onError(err)
 	}
 
 	w, err := fsnotify.NewWatcher()
@@ -100,7 +303,8 @@ func WatchFile(ctx context.Context, path string, onChange func(*Config)) error {
 					if cfg, err := Load(path); err == nil {
 						onChange(cfg)
 					} else {
-						sugar.Warnw("reload failed", "error", err)
+						sugar.Warnw("reload failed; keeping last good config", "error", err)
+						onError(err)
 					}
 				}
 			case err := <-w.Errors:
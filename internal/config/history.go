@@ -0,0 +1,179 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const historySize = 20
+
+// Reload is one entry of the reload history: an applied config with its changes, or a rejected one.
+type Reload struct {
+	Time    time.Time `json:"time"`
+	Applied bool      `json:"applied"`
+	Hash    string    `json:"hash,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Changes []string  `json:"changes,omitempty"`
+}
+
+// StateStatus is the applied config's metadata as reported by /api/v1/config.
+type StateStatus struct {
+	Hash        string     `json:"hash"`
+	LoadedAt    *time.Time `json:"loadedAt,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+	LastErrorAt *time.Time `json:"lastErrorAt,omitempty"`
+	History     []Reload   `json:"history"`
+}
+
+// Diff summarizes what changed from old to new: mode switches, added/removed watch patterns,
+// added/removed/changed security overrides and other changed sections.
+func Diff(old, new *Config) []string {
+	out := []string{}
+	if old.Mode.DataPlane != new.Mode.DataPlane {
+		out = append(out, fmt.Sprintf("mode.dataPlane: %q to %q", old.Mode.DataPlane, new.Mode.DataPlane))
+	}
+	watch := []struct {
+		name     string
+		old, new WatchSet
+	}{
+		{"watch.namespaces", old.Watch.Namespaces, new.Watch.Namespaces},
+		{"watch.pvcs", old.Watch.Pvcs, new.Watch.Pvcs},
+		{"watch.storageClasses", old.Watch.StorageClasses, new.Watch.StorageClasses},
+	}
+	for _, w := range watch {
+		out = append(out, listDiff(w.name+".include", w.old.Include, w.new.Include)...)
+		out = append(out, listDiff(w.name+".exclude", w.old.Exclude, w.new.Exclude)...)
+		if w.old.Selector != w.new.Selector {
+			out = append(out, fmt.Sprintf("%s.selector: %q to %q", w.name, w.old.Selector, w.new.Selector))
+		}
+		if w.old.OptIn != w.new.OptIn {
+			out = append(out, fmt.Sprintf("%s.optIn: %t to %t", w.name, w.old.OptIn, w.new.OptIn))
+		}
+	}
+	if !reflect.DeepEqual(old.Agents.SecurityDefaults, new.Agents.SecurityDefaults) {
+		out = append(out, "agents.securityDefaults changed")
+	}
+	if old.Agents.PodTemplate.Key() != new.Agents.PodTemplate.Key() {
+		out = append(out, "agents.podTemplate changed")
+	}
+	if !reflect.DeepEqual(old.Agents.Annotations, new.Agents.Annotations) {
+		out = append(out, "agents.annotations changed")
+	}
+	out = append(out, overrideDiff(old.Agents.SecurityOverrides, new.Agents.SecurityOverrides)...)
+	oldMounts, newMounts := []string{}, []string{}
+	for _, m := range old.MountPVCs {
+		oldMounts = append(oldMounts, m.PvcName)
+	}
+	for _, m := range new.MountPVCs {
+		newMounts = append(newMounts, m.PvcName)
+	}
+	out = append(out, listDiff("mountPVCs", oldMounts, newMounts)...)
+	if old.Agents.CleanupOnShutdown != new.Agents.CleanupOnShutdown {
+		out = append(out, fmt.Sprintf("agents.cleanupOnShutdown: %q to %q", old.Agents.CleanupOnShutdown, new.Agents.CleanupOnShutdown))
+	}
+	if old.RBAC.Mode != new.RBAC.Mode {
+		out = append(out, fmt.Sprintf("rbac.mode: %q to %q", old.RBAC.Mode, new.RBAC.Mode))
+	}
+	if !reflect.DeepEqual(old.RBAC.Rules, new.RBAC.Rules) {
+		out = append(out, fmt.Sprintf("rbac.rules changed (%d to %d rules)", len(old.RBAC.Rules), len(new.RBAC.Rules)))
+	}
+	if !reflect.DeepEqual(old.Audit.Sinks, new.Audit.Sinks) {
+		out = append(out, "audit.sinks changed")
+	}
+	if old.Policies.AllowSecurityOverrides != new.Policies.AllowSecurityOverrides {
+		out = append(out, fmt.Sprintf("policies.allowSecurityOverrides: %t to %t", old.Policies.AllowSecurityOverrides, new.Policies.AllowSecurityOverrides))
+	}
+	if !reflect.DeepEqual(old.Auth, new.Auth) {
+		out = append(out, "auth changed (applied on backend restart)")
+	}
+	return out
+}
+
+func listDiff(name string, old, new []string) []string {
+	out := []string{}
+	for _, p := range new {
+		if !contains(old, p) {
+			out = append(out, fmt.Sprintf("%s: added %q", name, p))
+		}
+	}
+	for _, p := range old {
+		if !contains(new, p) {
+			out = append(out, fmt.Sprintf("%s: removed %q", name, p))
+		}
+	}
+	return out
+}
+
+// overrideDiff matches overrides by their set conditions (namespace, pvcMatch, match, selector).
+func overrideDiff(old, new []OverrideSpec) []string {
+	id := func(o OverrideSpec) string {
+		parts := []string{}
+		for _, c := range []struct{ name, v string }{{"namespace", o.Namespace}, {"pvcMatch", o.PvcMatch}, {"match", o.Match}, {"selector", o.Selector}} {
+			if c.v != "" {
+				parts = append(parts, fmt.Sprintf("%s=%q", c.name, c.v))
+			}
+		}
+		return strings.Join(parts, ",")
+	}
+	out := []string{}
+	oldByID := map[string]OverrideSpec{}
+	for _, o := range old {
+		oldByID[id(o)] = o
+	}
+	newIDs := map[string]bool{}
+	for _, o := range new {
+		newIDs[id(o)] = true
+		prev, ok := oldByID[id(o)]
+		switch {
+		case !ok:
+			out = append(out, fmt.Sprintf("agents.securityOverrides: added %s", id(o)))
+		case !reflect.DeepEqual(prev, o):
+			out = append(out, fmt.Sprintf("agents.securityOverrides: changed %s", id(o)))
+		}
+	}
+	for _, o := range old {
+		if !newIDs[id(o)] {
+			out = append(out, fmt.Sprintf("agents.securityOverrides: removed %s", id(o)))
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Document renders c with its YAML field names (as in the ConfigMap) for JSON output.
+// Audit webhook header values are redacted since they usually carry credentials.
+func (c *Config) Document() (map[string]interface{}, error) {
+	cp := *c
+	cp.Audit.Sinks = make([]AuditSink, len(c.Audit.Sinks))
+	for i, s := range c.Audit.Sinks {
+		if len(s.Headers) > 0 {
+			h := make(map[string]string, len(s.Headers))
+			for k := range s.Headers {
+				h[k] = "<redacted>"
+			}
+			s.Headers = h
+		}
+		cp.Audit.Sinks[i] = s
+	}
+	b, err := yaml.Marshal(&cp)
+	if err != nil {
+		return nil, err
+	}
+	doc := map[string]interface{}{}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	const base = `
+mode: {dataPlane: agent-per-pvc}
+watch:
+  namespaces: {include: ["team-*"]}
+  pvcs: {include: ["*"], exclude: ["tmp-*"]}
+agents:
+  securityOverrides: [{pvcMatch: "db-*", runAsUser: 999}]
+  podTemplate: {priorityClassName: low}
+`
+	tests := []struct {
+		name string
+		yaml string
+		want []string
+	}{
+		{name: "unchanged", yaml: base, want: []string{}},
+		{name: "mode", yaml: `
+mode: {dataPlane: agent-per-namespace}
+watch:
+  namespaces: {include: ["team-*"]}
+  pvcs: {include: ["*"], exclude: ["tmp-*"]}
+agents:
+  securityOverrides: [{pvcMatch: "db-*", runAsUser: 999}]
+  podTemplate: {priorityClassName: low}
+`, want: []string{`mode.dataPlane: "agent-per-pvc" to "agent-per-namespace"`}},
+		{name: "watch patterns", yaml: `
+mode: {dataPlane: agent-per-pvc}
+watch:
+  namespaces: {include: ["team-*", "ops"], selector: "env=prod"}
+  pvcs: {include: ["*"], optIn: true}
+agents:
+  securityOverrides: [{pvcMatch: "db-*", runAsUser: 999}]
+  podTemplate: {priorityClassName: low}
+`, want: []string{
+			`watch.namespaces.include: added "ops"`,
+			`watch.namespaces.selector: "" to "env=prod"`,
+			`watch.pvcs.exclude: removed "tmp-*"`,
+			`watch.pvcs.optIn: false to true`,
+		}},
+		{name: "overrides", yaml: `
+mode: {dataPlane: agent-per-pvc}
+watch:
+  namespaces: {include: ["team-*"]}
+  pvcs: {include: ["*"], exclude: ["tmp-*"]}
+agents:
+  securityOverrides: [{pvcMatch: "db-*", runAsUser: 1000}, {namespace: "ops", match: "nfs", readOnly: true}]
+  podTemplate: {priorityClassName: low}
+`, want: []string{
+			`agents.securityOverrides: changed pvcMatch="db-*"`,
+			`agents.securityOverrides: added namespace="ops",match="nfs"`,
+		}},
+		{name: "override removed", yaml: `
+mode: {dataPlane: agent-per-pvc}
+watch:
+  namespaces: {include: ["team-*"]}
+  pvcs: {include: ["*"], exclude: ["tmp-*"]}
+agents:
+  podTemplate: {priorityClassName: low}
+`, want: []string{`agents.securityOverrides: removed pvcMatch="db-*"`}},
+		{name: "pod template", yaml: `
+mode: {dataPlane: agent-per-pvc}
+watch:
+  namespaces: {include: ["team-*"]}
+  pvcs: {include: ["*"], exclude: ["tmp-*"]}
+agents:
+  securityOverrides: [{pvcMatch: "db-*", runAsUser: 999}]
+  podTemplate: {priorityClassName: low, nodeSelector: {pool: storage}}
+`, want: []string{"agents.podTemplate changed"}},
+		{name: "policies and rbac", yaml: `
+mode: {dataPlane: agent-per-pvc}
+watch:
+  namespaces: {include: ["team-*"]}
+  pvcs: {include: ["*"], exclude: ["tmp-*"]}
+agents:
+  securityOverrides: [{pvcMatch: "db-*", runAsUser: 999}]
+  podTemplate: {priorityClassName: low}
+  cleanupOnShutdown: uninstall
+policies: {allowSecurityOverrides: true}
+rbac: {rules: [{namespaces: ["*"], verbs: [list]}]}
+audit: {sinks: [{type: stdout}]}
+`, want: []string{
+			`agents.cleanupOnShutdown: "" to "uninstall"`,
+			"rbac.rules changed (0 to 1 rules)",
+			"audit.sinks changed",
+			"policies.allowSecurityOverrides: false to true",
+		}},
+		{name: "mounts", yaml: `
+mode: {dataPlane: mount-in-backend}
+watch:
+  namespaces: {include: ["team-*"]}
+  pvcs: {include: ["*"], exclude: ["tmp-*"]}
+mountPVCs: [{pvcName: a, mountPath: /a}]
+agents:
+  securityOverrides: [{pvcMatch: "db-*", runAsUser: 999}]
+  podTemplate: {priorityClassName: low}
+`, want: []string{`mode.dataPlane: "agent-per-pvc" to "mount-in-backend"`, `mountPVCs: added "a"`}},
+		{name: "auth", yaml: base + `
+auth: {enabled: true, oidc: {issuerURL: "https://issuer", audiences: [cli]}}
+`, want: []string{"auth changed (applied on backend restart)"}},
+	}
+	old, err := Parse([]byte(base))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Parse([]byte(tt.yaml))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Diff(old, cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
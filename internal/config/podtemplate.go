@@ -0,0 +1,103 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// PodTemplate customizes generated agent Pods. Fields use the Kubernetes API types and names
+// (e.g. resources.requests.cpu, tolerations[].effect) and are decoded through their JSON form.
+type PodTemplate struct {
+	Resources         *corev1.ResourceRequirements `json:"resources,omitempty"`
+	Tolerations       []corev1.Toleration          `json:"tolerations,omitempty"`
+	NodeSelector      map[string]string            `json:"nodeSelector,omitempty"`
+	Affinity          *corev1.Affinity             `json:"affinity,omitempty"`
+	PriorityClassName string                       `json:"priorityClassName,omitempty"`
+	ImagePullSecrets  []string                     `json:"imagePullSecrets,omitempty"`
+	// Labels and Annotations are added to the Pod; the agent's own labels and annotations win
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// UnmarshalYAML decodes the template via JSON so the Kubernetes types (quantities, affinity
+// terms) decode as in manifests; unknown fields are errors like elsewhere in the config.
+func (p *PodTemplate) UnmarshalYAML(n *yaml.Node) error {
+	var raw interface{}
+	if err := n.Decode(&raw); err != nil {
+		return err
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("line %d: podTemplate: %v", n.Line, err)
+	}
+	type plain PodTemplate
+	var out plain
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&out); err != nil {
+		return fmt.Errorf("line %d: podTemplate: %v", n.Line, strings.TrimPrefix(err.Error(), "json: "))
+	}
+	*p = PodTemplate(out)
+	return nil
+}
+
+// MarshalYAML renders the template with its Kubernetes field names.
+func (p PodTemplate) MarshalYAML() (interface{}, error) {
+	var out map[string]interface{}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return out, json.Unmarshal(b, &out)
+}
+
+// Key is a stable rendering of the template; "" for an empty one.
+func (p PodTemplate) Key() string {
+	b, _ := json.Marshal(p)
+	if string(b) == "{}" {
+		return ""
+	}
+	return string(b)
+}
+
+// MergePodTemplate overlays o on base: set scalars and lists replace, maps merge per key.
+func MergePodTemplate(base, o PodTemplate) PodTemplate {
+	if o.Resources != nil {
+		base.Resources = o.Resources
+	}
+	if len(o.Tolerations) > 0 {
+		base.Tolerations = o.Tolerations
+	}
+	if o.Affinity != nil {
+		base.Affinity = o.Affinity
+	}
+	if o.PriorityClassName != "" {
+		base.PriorityClassName = o.PriorityClassName
+	}
+	if len(o.ImagePullSecrets) > 0 {
+		base.ImagePullSecrets = o.ImagePullSecrets
+	}
+	base.NodeSelector = mergeMap(base.NodeSelector, o.NodeSelector)
+	base.Labels = mergeMap(base.Labels, o.Labels)
+	base.Annotations = mergeMap(base.Annotations, o.Annotations)
+	return base
+}
+
+func mergeMap(base, o map[string]string) map[string]string {
+	if len(o) == 0 {
+		return base
+	}
+	out := make(map[string]string, len(base)+len(o))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range o {
+		out[k] = v
+	}
+	return out
+}
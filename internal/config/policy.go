@@ -0,0 +1,51 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// PolicySpec is the spec of a namespaced PVCViewerPolicy. It mirrors watch.pvcs,
+// watch.storageClasses and agents.securityOverrides for PVCs in the policy's namespace.
+//
+// Precedence: the cluster config's watch.namespaces decides whether the namespace is served at
+// all. In a served namespace with valid policies, a PVC is selected if any policy's pvcs and
+// storageClasses match it and the cluster watch.pvcs/storageClasses excludes do not; the cluster
+// includes are not used there. Policy overrides (in policy name order, only with
+// policies.allowSecurityOverrides) are listed before agents.securityOverrides, so they win ties of
+// equal priority; their IDs must lie within the agents.annotations bounds. PVC security
+// annotations still apply last.
+type PolicySpec struct {
+	Pvcs              WatchSet       `json:"pvcs"`
+	StorageClasses    WatchSet       `json:"storageClasses"`
+	SecurityOverrides []OverrideSpec `json:"securityOverrides,omitempty"`
+}
+
+// Policy is a decoded PVCViewerPolicy object.
+type Policy struct {
+	Namespace  string
+	Name       string
+	Generation int64
+	Spec       PolicySpec
+}
+
+// Validate checks a policy spec with the same rules as the matching cluster config sections.
+func (s PolicySpec) Validate() error {
+	var errs []error
+	errs = append(errs, globErrors("spec.pvcs.include", s.Pvcs.Include)...)
+	errs = append(errs, globErrors("spec.pvcs.exclude", s.Pvcs.Exclude)...)
+	errs = append(errs, globErrors("spec.storageClasses.include", s.StorageClasses.Include)...)
+	errs = append(errs, globErrors("spec.storageClasses.exclude", s.StorageClasses.Exclude)...)
+	if _, err := labels.Parse(s.Pvcs.Selector); err != nil {
+		errs = append(errs, fmt.Errorf("spec.pvcs.selector: %v", err))
+	}
+	if s.StorageClasses.Selector != "" || s.StorageClasses.OptIn {
+		errs = append(errs, errors.New("spec.storageClasses: selector and optIn are not supported for storage classes"))
+	}
+	for i, o := range s.SecurityOverrides {
+		errs = append(errs, overrideErrors(fmt.Sprintf("spec.securityOverrides[%d]", i), o)...)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,202 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// DataPlanes are the valid values of mode.dataPlane.
+var DataPlanes = []string{"agent-per-namespace", "agent-per-pvc", "mount-in-backend"}
+
+var unknownField = regexp.MustCompile(`^(line \d+): field (\S+) not found in type .*$`)
+
+var (
+	cleanupPolicies = []string{"", "never", "always", "uninstall"}
+	rbacModes       = []string{"", "rules", "kubernetes"}
+	rbacVerbs       = []string{"list", "download", "upload", "delete", "empty", "admin"}
+	auditSinkTypes  = []string{"stdout", "file", "rotating-file", "webhook"}
+)
+
+// Parse strictly decodes a config document (unknown fields are errors) and validates it.
+func Parse(b []byte) (*Config, error) {
+	var c Config
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil && !errors.Is(err, io.EOF) {
+		var te *yaml.TypeError
+		if errors.As(err, &te) {
+			// yaml.v3 names the (often anonymous) Go type; report the offending key instead
+			errs := make([]error, 0, len(te.Errors))
+			for _, e := range te.Errors {
+				errs = append(errs, errors.New(unknownField.ReplaceAllString(e, `$1: unknown field "$2"`)))
+			}
+			return nil, errors.Join(errs...)
+		}
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(b)
+	c.hash = hex.EncodeToString(sum[:])
+	return &c, nil
+}
+
+// Validate reports every semantic problem in c, joined into one error.
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...interface{}) { errs = append(errs, fmt.Errorf(format, args...)) }
+
+	if !oneOf(c.Mode.DataPlane, DataPlanes) {
+		add("mode.dataPlane: %q is not one of %v", c.Mode.DataPlane, DataPlanes)
+	}
+	for _, ws := range []struct {
+		name string
+		set  WatchSet
+	}{{"watch.namespaces", c.Watch.Namespaces}, {"watch.pvcs", c.Watch.Pvcs}, {"watch.storageClasses", c.Watch.StorageClasses}} {
+		errs = append(errs, globErrors(ws.name+".include", ws.set.Include)...)
+		errs = append(errs, globErrors(ws.name+".exclude", ws.set.Exclude)...)
+		if _, err := labels.Parse(ws.set.Selector); err != nil {
+			add("%s.selector: %v", ws.name, err)
+		}
+	}
+	if c.Watch.StorageClasses.Selector != "" || c.Watch.StorageClasses.OptIn {
+		add("watch.storageClasses: selector and optIn are not supported for storage classes")
+	}
+	if c.Mode.DataPlane == "mount-in-backend" {
+		for i, m := range c.MountPVCs {
+			if m.PvcName == "" || m.MountPath == "" {
+				add("mountPVCs[%d]: pvcName and mountPath are required", i)
+			}
+		}
+	}
+	errs = append(errs, securityErrors("agents.securityDefaults", c.Agents.SecurityDefaults)...)
+	errs = append(errs, podTemplateErrors("agents.podTemplate", c.Agents.PodTemplate)...)
+	for i, o := range c.Agents.SecurityOverrides {
+		errs = append(errs, overrideErrors(fmt.Sprintf("agents.securityOverrides[%d]", i), o)...)
+	}
+	for _, r := range []struct {
+		name string
+		rng  IDRange
+	}{{"runAsUser", c.Agents.Annotations.RunAsUser}, {"runAsGroup", c.Agents.Annotations.RunAsGroup}, {"fsGroup", c.Agents.Annotations.FSGroup}} {
+		if (r.rng.Min != nil && *r.rng.Min < 0) || (r.rng.Max != nil && *r.rng.Max < 0) {
+			add("agents.annotations.%s: bounds must not be negative", r.name)
+		}
+		if r.rng.Min != nil && r.rng.Max != nil && *r.rng.Min > *r.rng.Max {
+			add("agents.annotations.%s: min %d is greater than max %d", r.name, *r.rng.Min, *r.rng.Max)
+		}
+	}
+	if !oneOf(c.Agents.CleanupOnShutdown, cleanupPolicies) {
+		add("agents.cleanupOnShutdown: %q is not one of never, always, uninstall", c.Agents.CleanupOnShutdown)
+	}
+	if c.Auth.OIDC.IssuerURL != "" && c.Auth.OIDC.ClientID == "" && len(c.Auth.OIDC.Audiences) == 0 {
+		add("auth.oidc: clientID or audiences is required to check the token audience")
+	}
+	if c.Auth.OIDC.RedirectURL != "" && c.Auth.OIDC.ClientID == "" {
+		add("auth.oidc.redirectURL: clientID is required for login")
+	}
+	if !oneOf(c.RBAC.Mode, rbacModes) {
+		add("rbac.mode: %q is not one of rules, kubernetes", c.RBAC.Mode)
+	}
+	if c.RBAC.CacheTTL < 0 {
+		add("rbac.cacheTTL: must not be negative")
+	}
+	for i, r := range c.RBAC.Rules {
+		path := fmt.Sprintf("rbac.rules[%d]", i)
+		errs = append(errs, globErrors(path+".users", r.Users)...)
+		errs = append(errs, globErrors(path+".groups", r.Groups)...)
+		errs = append(errs, globErrors(path+".namespaces", r.Namespaces)...)
+		errs = append(errs, globErrors(path+".pvcs", r.PVCs)...)
+		for _, v := range r.Verbs {
+			if !oneOf(v, rbacVerbs) {
+				add("%s.verbs: unknown verb %q", path, v)
+			}
+		}
+	}
+	for i, s := range c.Audit.Sinks {
+		if !oneOf(s.Type, auditSinkTypes) {
+			add("audit.sinks[%d].type: %q is not one of %v", i, s.Type, auditSinkTypes)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func overrideErrors(path string, o OverrideSpec) []error {
+	var errs []error
+	if o.Match == "" && o.PvcMatch == "" && o.Namespace == "" && o.Selector == "" {
+		errs = append(errs, fmt.Errorf("%s: one of match, pvcMatch, namespace or selector is required", path))
+	}
+	errs = append(errs, globErrors(path+".match", []string{o.Match})...)
+	errs = append(errs, globErrors(path+".pvcMatch", []string{o.PvcMatch})...)
+	errs = append(errs, globErrors(path+".namespace", []string{o.Namespace})...)
+	if _, err := labels.Parse(o.Selector); err != nil {
+		errs = append(errs, fmt.Errorf("%s.selector: %v", path, err))
+	}
+	if o.PodTemplate != nil {
+		errs = append(errs, podTemplateErrors(path+".podTemplate", *o.PodTemplate)...)
+	}
+	return append(errs, securityErrors(path, o.SecuritySpec)...)
+}
+
+// podTemplateErrors rejects labels and annotations that would clash with the ones the backend manages.
+func podTemplateErrors(path string, p PodTemplate) []error {
+	var errs []error
+	for _, m := range []struct {
+		name string
+		kv   map[string]string
+	}{{"labels", p.Labels}, {"annotations", p.Annotations}} {
+		for k := range m.kv {
+			if k == "app" || strings.HasPrefix(k, "pvcviewer.k8s.io/") {
+				errs = append(errs, fmt.Errorf("%s.%s: %q is managed by pvc-viewer", path, m.name, k))
+			}
+		}
+	}
+	return errs
+}
+
+func securityErrors(path string, s SecuritySpec) []error {
+	var errs []error
+	for _, id := range []struct {
+		name string
+		v    *int64
+	}{{"runAsUser", s.RunAsUser}, {"runAsGroup", s.RunAsGroup}, {"fsGroup", s.FSGroup}} {
+		if id.v != nil && *id.v < 0 {
+			errs = append(errs, fmt.Errorf("%s.%s: %d is negative", path, id.name, *id.v))
+		}
+	}
+	for _, g := range s.SupplementalGroups {
+		if g < 0 {
+			errs = append(errs, fmt.Errorf("%s.supplementalGroups: %d is negative", path, g))
+		}
+	}
+	return errs
+}
+
+func globErrors(path string, patterns []string) []error {
+	var errs []error
+	for _, p := range patterns {
+		if !doublestar.ValidatePattern(p) {
+			errs = append(errs, fmt.Errorf("%s: invalid glob %q", path, p))
+		}
+	}
+	return errs
+}
+
+func oneOf(s string, set []string) bool {
+	for _, v := range set {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		// want lists substrings of the error; none means the config is valid
+		want []string
+	}{
+		{name: "no data plane", yaml: "", want: []string{"mode.dataPlane"}},
+		{name: "minimal", yaml: "mode: {dataPlane: agent-per-pvc}"},
+		{name: "unknown field", yaml: "mode: {dataPlane: agent-per-pvc, dataplane: x}", want: []string{`unknown field "dataplane"`}},
+		{name: "bad data plane", yaml: "mode: {dataPlane: sidecar}", want: []string{`mode.dataPlane: "sidecar"`}},
+		{name: "bad globs and selector", yaml: `
+mode: {dataPlane: agent-per-pvc}
+watch:
+  pvcs: {include: ["[a"], selector: "a in (b"}
+  storageClasses: {optIn: true}`, want: []string{"watch.pvcs.include", "watch.pvcs.selector", "watch.storageClasses"}},
+		{name: "mount without path", yaml: `
+mode: {dataPlane: mount-in-backend}
+mountPVCs: [{pvcName: data}]`, want: []string{"mountPVCs[0]"}},
+		{name: "negative IDs", yaml: `
+mode: {dataPlane: agent-per-pvc}
+agents:
+  securityDefaults: {runAsUser: -1, supplementalGroups: [-2]}
+  securityOverrides: [{fsGroup: -3}]`, want: []string{"securityDefaults.runAsUser", "securityDefaults.supplementalGroups", "securityOverrides[0]: one of", "securityOverrides[0].fsGroup"}},
+		{name: "managed pod template label", yaml: `
+mode: {dataPlane: agent-per-pvc}
+agents: {podTemplate: {labels: {app: x}}}`, want: []string{`agents.podTemplate.labels: "app"`}},
+		{name: "annotation ranges", yaml: `
+mode: {dataPlane: agent-per-pvc}
+agents:
+  annotations:
+    runAsUser: {min: 2000, max: 1000}
+    fsGroup: {min: -1}`, want: []string{"runAsUser: min 2000", "fsGroup: bounds"}},
+		{name: "oidc without audience", yaml: `
+mode: {dataPlane: agent-per-pvc}
+auth: {enabled: true, oidc: {issuerURL: "https://issuer"}}`, want: []string{"auth.oidc: clientID or audiences"}},
+		{name: "oidc with audiences", yaml: `
+mode: {dataPlane: agent-per-pvc}
+auth: {enabled: true, oidc: {issuerURL: "https://issuer", audiences: [cli]}}`},
+		{name: "oidc login without client", yaml: `
+mode: {dataPlane: agent-per-pvc}
+auth: {enabled: true, oidc: {issuerURL: "https://issuer", audiences: [cli], redirectURL: "https://x/cb"}}`, want: []string{"auth.oidc.redirectURL"}},
+		{name: "rbac", yaml: `
+mode: {dataPlane: agent-per-pvc}
+rbac: {mode: abac, cacheTTL: -1s, rules: [{namespaces: ["*"], verbs: [list, write]}]}`, want: []string{"rbac.mode", "rbac.cacheTTL", `unknown verb "write"`}},
+		{name: "audit sink", yaml: `
+mode: {dataPlane: agent-per-pvc}
+audit: {sinks: [{type: syslog}]}`, want: []string{"audit.sinks[0].type"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.yaml))
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("no error, want %v", tt.want)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(err.Error(), w) {
+					t.Errorf("error %q does not mention %q", err, w)
+				}
+			}
+		})
+	}
+}
@@ -31,6 +31,20 @@ func JoinSecure(root, requestPath string) (string, error) {
 	return final, nil
 }
 
+// JoinSecureNoFollow is JoinSecure for operations on an entry itself: the parent directory is
+// resolved like JoinSecure, a symlink in the final component is not followed.
+func JoinSecureNoFollow(root, requestPath string) (string, error) {
+	clean := filepath.Clean("/" + requestPath)
+	if clean == "/" {
+		return JoinSecure(root, clean)
+	}
+	parent, err := JoinSecure(root, filepath.Dir(clean))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(parent, filepath.Base(clean)), nil
+}
+
 func resolveWithinRoot(root, relPath string) (string, error) {
 	rootAbs, err := filepath.Abs(root)
 	if err != nil {
@@ -45,7 +59,7 @@ func resolveWithinRoot(root, relPath string) (string, error) {
 		fi, err := os.Lstat(cur)
 		if err != nil {
 			// Not existing yet; still ensure prefix check
-			if !within(rootAbs, cur) {
+			if !Within(rootAbs, cur) {
 				return "", ErrPathTraversal
 			}
 			continue
@@ -66,19 +80,39 @@ func resolveWithinRoot(root, relPath string) (string, error) {
 			if err != nil {
 				return "", err
 			}
-			if !within(rootAbs, next) {
+			if !Within(rootAbs, next) {
 				return "", ErrPathTraversal
 			}
 			cur = next
 		}
-		if !within(rootAbs, cur) {
+		if !Within(rootAbs, cur) {
 			return "", ErrPathTraversal
 		}
 	}
 	return cur, nil
 }
 
-func within(root, path string) bool {
+// SymlinkWithin reports whether a symlink at linkPath pointing to target would resolve inside
+// root, following further links like JoinSecure. Dangling links inside root count as within.
+func SymlinkWithin(root, linkPath, target string) bool {
+	rootAbs, err := filepath.Abs(root)
+	if err != nil {
+		return false
+	}
+	next := target
+	if !filepath.IsAbs(next) {
+		next = filepath.Join(filepath.Dir(linkPath), next)
+	}
+	rel, err := filepath.Rel(rootAbs, filepath.Clean(next))
+	if err != nil || !Within(rootAbs, next) {
+		return false
+	}
+	_, err = resolveWithinRoot(rootAbs, rel)
+	return err == nil
+}
+
+// Within reports whether path is root or below it (lexically).
+func Within(root, path string) bool {
 	root = filepath.Clean(root)
 	path = filepath.Clean(path)
 	if root == path {
@@ -0,0 +1,82 @@
+package kube
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// AccessReviewer asks the API server (SubjectAccessReview) whether a user may perform
+// an action, caching allow and deny decisions for TTL.
+type AccessReviewer struct {
+	Client kubernetes.Interface
+	TTL    time.Duration
+
+	mu    sync.Mutex
+	cache map[string]reviewResult
+}
+
+type reviewResult struct {
+	allowed bool
+	expires time.Time
+}
+
+// maxCachedReviews bounds the cache; expired entries are purged when it is exceeded.
+const maxCachedReviews = 10000
+
+func NewAccessReviewer(client kubernetes.Interface, ttl time.Duration) *AccessReviewer {
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	return &AccessReviewer{Client: client, TTL: ttl, cache: map[string]reviewResult{}}
+}
+
+// Allowed reports whether user/groups may perform attrs.
+func (a *AccessReviewer) Allowed(ctx context.Context, user string, groups []string, attrs authorizationv1.ResourceAttributes) (bool, error) {
+	k := cacheKey(user, groups, attrs)
+	now := time.Now()
+	a.mu.Lock()
+	if r, ok := a.cache[k]; ok && now.Before(r.expires) {
+		a.mu.Unlock()
+		return r.allowed, nil
+	}
+	a.mu.Unlock()
+
+	sar := &authorizationv1.SubjectAccessReview{Spec: authorizationv1.SubjectAccessReviewSpec{
+		User:               user,
+		Groups:             groups,
+		ResourceAttributes: &attrs,
+	}}
+	res, err := a.Client.AuthorizationV1().SubjectAccessReviews().Create(ctx, sar, metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+	allowed := res.Status.Allowed && !res.Status.Denied
+
+	a.mu.Lock()
+	if len(a.cache) >= maxCachedReviews {
+		for ck, r := range a.cache {
+			if now.After(r.expires) {
+				delete(a.cache, ck)
+			}
+		}
+		if len(a.cache) >= maxCachedReviews {
+			a.cache = map[string]reviewResult{}
+		}
+	}
+	a.cache[k] = reviewResult{allowed: allowed, expires: now.Add(a.TTL)}
+	a.mu.Unlock()
+	return allowed, nil
+}
+
+func cacheKey(user string, groups []string, attrs authorizationv1.ResourceAttributes) string {
+	g := append([]string{}, groups...)
+	sort.Strings(g)
+	return strings.Join([]string{user, strings.Join(g, ","), attrs.Verb, attrs.Group, attrs.Resource, attrs.Subresource, attrs.Namespace, attrs.Name}, "\x00")
+}
@@ -0,0 +1,131 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// sarClient answers SubjectAccessReviews with decide and counts the calls.
+func sarClient(decide func(spec authorizationv1.SubjectAccessReviewSpec) (authorizationv1.SubjectAccessReviewStatus, error), calls *int) *fake.Clientset {
+	c := fake.NewSimpleClientset()
+	c.PrependReactor("create", "subjectaccessreviews", func(a k8stesting.Action) (bool, runtime.Object, error) {
+		*calls++
+		sar := a.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		st, err := decide(sar.Spec)
+		if err != nil {
+			return true, nil, err
+		}
+		sar.Status = st
+		return true, sar, nil
+	})
+	return c
+}
+
+func TestAccessReviewerAllowed(t *testing.T) {
+	get := authorizationv1.ResourceAttributes{Namespace: "a", Resource: "persistentvolumeclaims", Name: "p", Verb: "get"}
+	tests := []struct {
+		name   string
+		status authorizationv1.SubjectAccessReviewStatus
+		err    error
+		want   bool
+	}{
+		{name: "allowed", status: authorizationv1.SubjectAccessReviewStatus{Allowed: true}, want: true},
+		{name: "no opinion", status: authorizationv1.SubjectAccessReviewStatus{}},
+		{name: "denied wins", status: authorizationv1.SubjectAccessReviewStatus{Allowed: true, Denied: true}},
+		{name: "error fails closed", err: errors.New("apiserver down")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			r := NewAccessReviewer(sarClient(func(authorizationv1.SubjectAccessReviewSpec) (authorizationv1.SubjectAccessReviewStatus, error) {
+				return tt.status, tt.err
+			}, &calls), time.Minute)
+			got, err := r.Allowed(context.Background(), "alice", []string{"b", "a"}, get)
+			if got != tt.want || (err != nil) != (tt.err != nil) {
+				t.Fatalf("Allowed = %v, %v; want %v", got, err, tt.want)
+			}
+			// decisions are cached (group order does not matter); errors are not
+			_, _ = r.Allowed(context.Background(), "alice", []string{"a", "b"}, get)
+			wantCalls := 1
+			if tt.err != nil {
+				wantCalls = 2
+			}
+			if calls != wantCalls {
+				t.Errorf("%d SubjectAccessReviews, want %d", calls, wantCalls)
+			}
+		})
+	}
+}
+
+func TestAccessReviewerCache(t *testing.T) {
+	calls := 0
+	r := NewAccessReviewer(sarClient(func(spec authorizationv1.SubjectAccessReviewSpec) (authorizationv1.SubjectAccessReviewStatus, error) {
+		return authorizationv1.SubjectAccessReviewStatus{Allowed: spec.User == "alice"}, nil
+	}, &calls), time.Minute)
+	ctx := context.Background()
+	attrs := authorizationv1.ResourceAttributes{Namespace: "a", Resource: "persistentvolumeclaims", Name: "p", Verb: "get"}
+
+	_, _ = r.Allowed(ctx, "alice", nil, attrs)
+	_, _ = r.Allowed(ctx, "alice", nil, attrs)
+	if calls != 1 {
+		t.Fatalf("cache miss on repeated review: %d calls", calls)
+	}
+	// every attribute is part of the key
+	other := attrs
+	other.Verb = "update"
+	if _, _ = r.Allowed(ctx, "alice", nil, other); calls != 2 {
+		t.Fatalf("different verb served from cache")
+	}
+	if ok, _ := r.Allowed(ctx, "bob", nil, attrs); ok || calls != 3 {
+		t.Fatalf("different user served from cache")
+	}
+
+	// expired entries are reviewed again
+	r.mu.Lock()
+	for k, v := range r.cache {
+		v.expires = time.Now().Add(-time.Second)
+		r.cache[k] = v
+	}
+	r.mu.Unlock()
+	if _, _ = r.Allowed(ctx, "alice", nil, attrs); calls != 4 {
+		t.Fatalf("expired decision served from cache")
+	}
+}
+
+func TestAccessReviewerCacheCap(t *testing.T) {
+	calls := 0
+	r := NewAccessReviewer(sarClient(func(authorizationv1.SubjectAccessReviewSpec) (authorizationv1.SubjectAccessReviewStatus, error) {
+		return authorizationv1.SubjectAccessReviewStatus{Allowed: true}, nil
+	}, &calls), time.Minute)
+	ctx := context.Background()
+	now := time.Now()
+	fill := func(n int, expires time.Time) {
+		for i := 0; i < n; i++ {
+			r.cache[fmt.Sprintf("%v-%d", expires.UnixNano(), i)] = reviewResult{allowed: true, expires: expires}
+		}
+	}
+	attrs := authorizationv1.ResourceAttributes{Resource: "pods", Verb: "delete"}
+
+	// expired entries are purged first
+	fill(maxCachedReviews/2, now.Add(-time.Minute))
+	fill(maxCachedReviews/2, now.Add(time.Minute))
+	_, _ = r.Allowed(ctx, "alice", nil, attrs)
+	if n := len(r.cache); n != maxCachedReviews/2+1 {
+		t.Fatalf("cache holds %d entries after purging expired ones", n)
+	}
+
+	// all live: the cache is reset rather than growing past the cap
+	fill(maxCachedReviews, now.Add(time.Hour))
+	_, _ = r.Allowed(ctx, "bob", nil, attrs)
+	if n := len(r.cache); n != 1 {
+		t.Fatalf("cache holds %d entries, want a reset to 1", n)
+	}
+}
@@ -6,25 +6,41 @@ type Matcher struct{ include, exclude []string }
 
 func New(include, exclude []string) Matcher { return Matcher{include: include, exclude: exclude} }
 
-func (m Matcher) Match(s string) bool {
+// Result explains a match decision: the include pattern that admitted s and the exclude
+// pattern that rejected it, if any.
+type Result struct {
+	Matched bool   `json:"matched"`
+	Include string `json:"include,omitempty"`
+	Exclude string `json:"exclude,omitempty"`
+}
+
+func (m Matcher) Match(s string) bool { return m.Explain(s).Matched }
+
+// Explain evaluates s like Match and reports which patterns decided.
+func (m Matcher) Explain(s string) Result {
 	// empty include => no match by default per spec
 	if len(m.include) == 0 {
-		return false
+		return Result{}
 	}
-	included := false
+	r, included := Result{}, false
 	for _, p := range m.include {
 		if ok, _ := doublestar.Match(p, s); ok {
-			included = true
+			r.Include, included = p, true
 			break
 		}
 	}
 	if !included {
-		return false
+		return r
 	}
 	for _, p := range m.exclude {
 		if ok, _ := doublestar.Match(p, s); ok {
-			return false
+			r.Exclude = p
+			return r
 		}
 	}
-	return true
+	r.Matched = true
+	return r
 }
+
+// Empty reports whether the include list is empty, i.e. nothing can match.
+func (m Matcher) Empty() bool { return len(m.include) == 0 }
@@ -0,0 +1,64 @@
+package matcher
+
+import (
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// AnnotationEnabled opts a namespace or PVC out ("false") or, with opt-in matching, in ("true").
+const AnnotationEnabled = "pvcviewer.k8s.io/enabled"
+
+// ObjectMatcher matches Kubernetes objects by name globs, a label selector and the
+// AnnotationEnabled annotation. All configured conditions must hold.
+type ObjectMatcher struct {
+	Names    Matcher
+	selector labels.Selector // nil: no label condition
+	optIn    bool
+}
+
+// ObjectResult explains an ObjectMatcher decision.
+type ObjectResult struct {
+	Matched bool   `json:"matched"`
+	Names   Result `json:"names"`
+	// Selector is the configured label selector; SelectorMatched is only meaningful when it is set
+	Selector        string `json:"selector,omitempty"`
+	SelectorMatched bool   `json:"selectorMatched,omitempty"`
+	// OptedOut: annotated enabled=false; OptInMissing: opt-in required but not annotated enabled=true
+	OptedOut     bool `json:"optedOut,omitempty"`
+	OptInMissing bool `json:"optInMissing,omitempty"`
+}
+
+// NewObject builds an ObjectMatcher; selector uses kubectl syntax (e.g. "team in (a,b),env!=prod").
+func NewObject(include, exclude []string, selector string, optIn bool) (ObjectMatcher, error) {
+	m := ObjectMatcher{Names: New(include, exclude), optIn: optIn}
+	if selector != "" {
+		sel, err := labels.Parse(selector)
+		if err != nil {
+			return ObjectMatcher{}, err
+		}
+		m.selector = sel
+	}
+	return m, nil
+}
+
+func (m ObjectMatcher) Match(name string, lbls, annotations map[string]string) bool {
+	return m.Explain(name, lbls, annotations).Matched
+}
+
+// Explain evaluates every condition, without stopping at the first failing one.
+func (m ObjectMatcher) Explain(name string, lbls, annotations map[string]string) ObjectResult {
+	r := ObjectResult{Names: m.Names.Explain(name)}
+	r.Matched = r.Names.Matched
+	if m.selector != nil {
+		r.Selector = m.selector.String()
+		r.SelectorMatched = m.selector.Matches(labels.Set(lbls))
+		r.Matched = r.Matched && r.SelectorMatched
+	}
+	v := annotations[AnnotationEnabled]
+	if v == "false" {
+		r.OptedOut, r.Matched = true, false
+	}
+	if m.optIn && v != "true" {
+		r.OptInMissing, r.Matched = true, false
+	}
+	return r
+}